@@ -0,0 +1,76 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+
+	"github.com/alecthomas/kong"
+	"github.com/antoniszymanski/tgtt-go/cmd/tgtt/internal"
+)
+
+type cmdCheck struct {
+	Path string `arg:"" type:"path" default:"tgtt.jsonc"`
+}
+
+func (c *cmdCheck) Run(ctx *kong.Context) error {
+	cfg, err := loadConfig(c.Path)
+	if err != nil {
+		return err
+	}
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	pkg, err := transpile(runCtx, cfg)
+	if err != nil {
+		return err
+	}
+
+	files, err := render(cfg, pkg)
+	if err != nil {
+		return err
+	}
+
+	var stale int
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		oldName := "a/" + name
+		old, err := os.ReadFile(filepath.Join(cfg.OutputPath, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			oldName = "/dev/null"
+		} else if err != nil {
+			return err
+		}
+		if diff := internal.Diff(oldName, old, "b/"+name, files[name]); diff != nil {
+			stale++
+			ctx.Stdout.Write(diff) //nolint:errcheck
+		}
+	}
+
+	m, err := readManifest(cfg.OutputPath)
+	if err != nil {
+		return err
+	}
+	for _, name := range m.staleFiles(slices.Collect(maps.Keys(files))) {
+		old, err := os.ReadFile(filepath.Join(cfg.OutputPath, filepath.FromSlash(name)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		stale++
+		ctx.Stdout.Write(internal.Diff("a/"+name, old, "/dev/null", nil)) //nolint:errcheck
+	}
+
+	if stale > 0 {
+		return fmt.Errorf("%d generated file(s) in %s are out of date", stale, cfg.OutputPath)
+	}
+	return nil
+}
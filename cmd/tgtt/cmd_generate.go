@@ -4,68 +4,272 @@
 package main
 
 import (
+	"archive/tar"
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
-	jsonc "github.com/DisposaBoy/JsonConfigReader"
-	"github.com/antoniszymanski/sanefmt-go"
-	"github.com/antoniszymanski/tgtt-go/cmd/tgtt/config"
-	"github.com/antoniszymanski/tgtt-go/tgtt"
+	"github.com/alecthomas/kong"
+	"golang.org/x/tools/go/packages"
 )
 
 type cmdGenerate struct {
-	Path string `arg:"" type:"path" default:"tgtt.jsonc"`
+	Path         string        `arg:"" type:"path" default:"tgtt.jsonc"`
+	Watch        bool          `short:"w" xor:"mode" help:"Regenerate whenever the Go sources change."`
+	Interval     time.Duration `default:"500ms" help:"Polling interval of watch mode."`
+	Debounce     time.Duration `default:"200ms" help:"Quiet period after a change before regenerating."`
+	DryRun       bool          `short:"n" xor:"mode" help:"List the files that would be written without touching the disk."`
+	Stdout       bool          `xor:"mode" help:"Write all files to stdout instead of the output path."`
+	StdoutFormat string        `enum:"text,tar" default:"text" help:"Format of --stdout (${enum})."`
 }
 
-func (c *cmdGenerate) Run() error {
-	var f *os.File
-	var err error
-	if c.Path != "-" {
-		f, err = os.Open(c.Path)
-		if err != nil {
-			return err
+func (c *cmdGenerate) Run(ctx *kong.Context) error {
+	runCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	switch {
+	case c.DryRun, c.Stdout:
+		return c.preview(runCtx, ctx)
+	case c.Watch:
+		if c.Path == "-" {
+			return errors.New("watch mode cannot read the config from stdin")
 		}
-		defer f.Close() //nolint:errcheck
-	} else {
-		f = os.Stdin
+		return c.watch(runCtx, ctx)
+	default:
+		_, err := c.generate(runCtx, ctx)
+		return err
 	}
+}
 
-	data, err := io.ReadAll(jsonc.New(f))
+// preview implements --dry-run and --stdout.
+func (c *cmdGenerate) preview(runCtx context.Context, ctx *kong.Context) error {
+	cfg, err := loadConfig(c.Path)
 	if err != nil {
 		return err
 	}
-	var cfg config.Config
-	if err = cfg.UnmarshalJSON(data); err != nil {
+	pkg, err := transpile(runCtx, cfg)
+	if err != nil {
 		return err
 	}
-
-	pkg, err := tgtt.Transpile(tgtt.TranspileOptions{
-		PrimaryPackage:    cfg.PrimaryPackage,
-		SecondaryPackages: cfg.SecondaryPackages,
-		TypeMappings:      cfg.TypeMappings,
-		IncludeUnexported: cfg.IncludeUnexported,
-		FallbackType:      cfg.FallbackType,
-	})
+	files, err := render(cfg, pkg)
 	if err != nil {
 		return err
 	}
 
-	cfg.OutputPath = filepath.Clean(cfg.OutputPath)
-	if err = os.MkdirAll(cfg.OutputPath, 0750); err != nil {
+	names := slices.Sorted(maps.Keys(files))
+	if c.Stdout {
+		if c.StdoutFormat == "tar" {
+			return writeTar(ctx.Stdout, names, files)
+		}
+		for i, name := range names {
+			if i > 0 {
+				fmt.Fprintln(ctx.Stdout)
+			}
+			fmt.Fprintf(ctx.Stdout, "// ==> %s <==\n", name)
+			data := files[name]
+			ctx.Stdout.Write(data) //nolint:errcheck
+			if !bytes.HasSuffix(data, []byte("\n")) {
+				fmt.Fprintln(ctx.Stdout)
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		data := files[name]
+		status := "new"
+		old, err := os.ReadFile(filepath.Join(cfg.OutputPath, filepath.FromSlash(name)))
+		switch {
+		case err == nil && bytes.Equal(old, data):
+			status = "unchanged"
+		case err == nil:
+			status = "changed"
+		case !errors.Is(err, fs.ErrNotExist):
+			return err
+		}
+		fmt.Fprintf(ctx.Stdout, "%-9s %8d %s\n", status, len(data), name)
+	}
+	m, err := readManifest(cfg.OutputPath)
+	if err != nil {
 		return err
 	}
-	return pkg.Render(tgtt.RenderOptions{
-		Write: func(moduleName string, data []byte) (err error) {
-			if cfg.Format {
-				data, err = sanefmt.Format(bytes.NewReader(data))
-				if err != nil {
-					return err
+	for _, name := range m.staleFiles(names) {
+		fmt.Fprintf(ctx.Stdout, "%-9s %8s %s\n", "removed", "-", name)
+	}
+	return nil
+}
+
+func writeTar(w io.Writer, names []string, files map[string][]byte) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, name := range names {
+		data := files[name]
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// generate runs the pipeline once and returns the directories of the Go
+// source files that the output depends on.
+func (c *cmdGenerate) generate(runCtx context.Context, ctx *kong.Context) ([]string, error) {
+	cfg, err := loadConfig(c.Path)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := transpile(runCtx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := render(cfg, pkg)
+	if err != nil {
+		return nil, err
+	}
+	changed, err := writeOutput(cfg.OutputPath, files)
+	if err != nil {
+		return nil, err
+	}
+	if c.Watch {
+		ctx.Printf("generated %d module(s), %d changed", len(pkg), changed)
+	}
+
+	dirs := make(map[string]struct{})
+	for _, mod := range pkg {
+		for _, file := range mod.GoFiles {
+			dirs[filepath.Dir(file)] = struct{}{}
+		}
+	}
+	return slices.Collect(maps.Keys(dirs)), nil
+}
+
+// watch regenerates whenever the sources change, until runCtx is done.
+func (c *cmdGenerate) watch(runCtx context.Context, ctx *kong.Context) error {
+	var dirs []string
+	for {
+		newDirs, err := c.generate(runCtx, ctx)
+		if runCtx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			ctx.Errorf("%v", err)
+			if dirs == nil {
+				// no run has succeeded yet, so watch the configured packages
+				dirs = c.packageDirs()
+			}
+		} else {
+			dirs = newDirs
+		}
+
+		snapshot := c.snapshot(dirs)
+		for {
+			if !sleep(runCtx, c.Interval) {
+				return nil
+			}
+			current := c.snapshot(dirs)
+			if maps.Equal(snapshot, current) {
+				continue
+			}
+			// wait until the sources stop changing
+			for {
+				snapshot = current
+				if !sleep(runCtx, c.Debounce) {
+					return nil
+				}
+				current = c.snapshot(dirs)
+				if maps.Equal(snapshot, current) {
+					break
 				}
 			}
-			path := cfg.OutputPath + string(filepath.Separator) + moduleName + ".ts"
-			return os.WriteFile(path, data, 0600)
-		},
-	})
+			break
+		}
+	}
+}
+
+// sleep waits for d and reports whether ctx is still active.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// packageDirs returns the directories of the packages named in the config,
+// without type-checking them. It returns nil if they cannot be listed.
+func (c *cmdGenerate) packageDirs() []string {
+	cfg, err := loadConfig(c.Path)
+	if err != nil {
+		return nil
+	}
+	patterns := []string{"pattern=" + cfg.PrimaryPackage.Path}
+	for _, pkgOpts := range cfg.SecondaryPackages {
+		patterns = append(patterns, "pattern="+pkgOpts.Path)
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedFiles}, patterns...)
+	if err != nil {
+		return nil
+	}
+	dirs := make(map[string]struct{})
+	for _, pkg := range pkgs {
+		for _, file := range pkg.GoFiles {
+			dirs[filepath.Dir(file)] = struct{}{}
+		}
+		if pkg.Dir != "" {
+			dirs[pkg.Dir] = struct{}{}
+		}
+	}
+	return slices.Collect(maps.Keys(dirs))
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshot records the state of the Go source files in dirs and of the
+// config file.
+func (c *cmdGenerate) snapshot(dirs []string) map[string]fileState {
+	s := make(map[string]fileState)
+	stat := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			s[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	stat(c.Path)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".go") {
+				stat(filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return s
 }
@@ -41,7 +41,7 @@ func (c *cmdInit) Run() error {
 		f = os.Stdout
 	}
 
-	var cfg config.Config
+	cfg := config.Config{Layout: "flat", Barrel: "none", OutputMode: "multi-file"}
 	if !c.NoSchema {
 		cfg.Schema = c.SchemaPath
 	}
@@ -0,0 +1,43 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/alecthomas/kong"
+	"github.com/antoniszymanski/tgtt-go/tgtt"
+)
+
+type cmdPresets struct {
+	Name string `arg:"" optional:"" help:"Show the type mappings of the named preset."`
+}
+
+func (c *cmdPresets) Run(ctx *kong.Context) error {
+	w := tabwriter.NewWriter(ctx.Stdout, 0, 0, 2, ' ', 0)
+	if c.Name == "" {
+		for _, p := range tgtt.Presets() {
+			fmt.Fprintf(w, "%s\t%s\n", p.Name, p.Description)
+		}
+		return w.Flush()
+	}
+
+	p, ok := tgtt.LookupPreset(c.Name)
+	if !ok {
+		return fmt.Errorf("unknown preset %q", c.Name)
+	}
+	for _, key := range slices.Sorted(maps.Keys(p.Mappings)) {
+		m := p.Mappings[key]
+		if m.From == "" {
+			fmt.Fprintf(w, "%s\t%s\n", key, m.Type)
+		} else {
+			fmt.Fprintf(w, "%s\t%s from %s\n", key, m.Type, strconv.Quote(m.From))
+		}
+	}
+	return w.Flush()
+}
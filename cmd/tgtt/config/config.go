@@ -16,14 +16,27 @@
 )
 
 type Config struct {
-	Schema            string                              `json:"$schema,omitzero"`
-	Format            bool                                `json:"format"`
-	IncludeUnexported bool                                `json:"include_unexported"`
-	FallbackType      string                              `json:"fallback_type" jsonschema:"default=any"`
-	OutputPath        string                              `json:"output_path" jsonschema:"required,minLength=1"`
-	TypeMappings      internal.Object[string, string]     `json:"type_mappings"`
-	PrimaryPackage    tgtt.PackageOptions                 `json:"primary_package" jsonschema:"required"`
-	SecondaryPackages internal.Array[tgtt.PackageOptions] `json:"secondary_packages"`
+	Schema            string                                    `json:"$schema,omitzero"`
+	Format            bool                                      `json:"format"`
+	IncludeUnexported bool                                      `json:"include_unexported"`
+	FallbackType      string                                    `json:"fallback_type" jsonschema:"default=any"`
+	OutputPath        string                                    `json:"output_path" jsonschema:"required,minLength=1"`
+	Layout            string                                    `json:"layout" jsonschema:"enum=flat,enum=tree,default=flat"`
+	Barrel            string                                    `json:"barrel" jsonschema:"enum=none,enum=namespace,enum=flatten,default=none"`
+	OutputMode        string                                    `json:"output_mode" jsonschema:"enum=multi-file,enum=single-file,default=multi-file"`
+	Declaration       bool                                      `json:"declaration"`
+	FileExtension     string                                    `json:"file_extension" jsonschema:"default=.ts"`
+	ImportTypeOnly    bool                                      `json:"import_type_only"`
+	ImportExtension   string                                    `json:"import_extension"`
+	ImportBasePath    string                                    `json:"import_base_path" jsonschema:"default=."`
+	ModuleNames       internal.Object[string, string]           `json:"module_names"`
+	TypeMappings      internal.Object[string, tgtt.TypeMapping] `json:"type_mappings"`
+	Presets           internal.Array[string]                    `json:"presets"`
+	Strict            bool                                      `json:"strict"`
+	StrictAllow       internal.Array[string]                    `json:"strict_allow"`
+	Cache             bool                                      `json:"cache"`
+	PrimaryPackage    tgtt.PackageOptions                       `json:"primary_package" jsonschema:"required"`
+	SecondaryPackages internal.Array[tgtt.PackageOptions]       `json:"secondary_packages"`
 }
 
 func (c *Config) UnmarshalJSON(data []byte) error {
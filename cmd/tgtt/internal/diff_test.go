@@ -0,0 +1,231 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestDiffEqual(t *testing.T) {
+	if d := Diff("a", []byte("x\n"), "b", []byte("x\n")); d != nil {
+		t.Fatalf("got %q, want nil", d)
+	}
+}
+
+func TestDiffHunks(t *testing.T) {
+	var old, new strings.Builder
+	for i := 1; i <= 20; i++ {
+		fmt.Fprintf(&old, "%d\n", i)
+		switch i {
+		case 2:
+			new.WriteString("two\n")
+		case 15:
+			continue
+		default:
+			fmt.Fprintf(&new, "%d\n", i)
+		}
+	}
+	new.WriteString("21\n")
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ 1
+-2
++two
+ 3
+ 4
+ 5
+@@ -12,9 +12,9 @@
+ 12
+ 13
+ 14
+-15
+ 16
+ 17
+ 18
+ 19
+ 20
++21
+`
+	if got := string(Diff("a", []byte(old.String()), "b", []byte(new.String()))); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDiffAddedAndRemovedFiles(t *testing.T) {
+	tests := []struct {
+		old, new string
+		want     string
+	}{
+		{"", "a\nb\n", "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"a\nb\n", "", "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+	}
+	for _, tt := range tests {
+		if got := string(Diff("a", []byte(tt.old), "b", []byte(tt.new))); got != tt.want {
+			t.Errorf("Diff(%q, %q):\ngot:\n%s\nwant:\n%s", tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
+func TestDiffNoNewlineAtEOF(t *testing.T) {
+	tests := []struct {
+		old, new string
+		want     string
+	}{
+		{
+			"a\nb", "a\nb\n",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			"a\nb\n", "a\nc",
+			"--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		got := Diff("a", []byte(tt.old), "b", []byte(tt.new))
+		if string(got) != tt.want {
+			t.Errorf("Diff(%q, %q):\ngot:\n%s\nwant:\n%s", tt.old, tt.new, got, tt.want)
+		}
+		if patched, err := applyDiff([]byte(tt.old), got); err != nil {
+			t.Errorf("Diff(%q, %q): %v", tt.old, tt.new, err)
+		} else if string(patched) != tt.new {
+			t.Errorf("Diff(%q, %q): patch produced %q", tt.old, tt.new, patched)
+		}
+	}
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range 500 {
+		old := randomText(r)
+		new := mutateText(r, old)
+		d := Diff("a", old, "b", new)
+		if bytes.Equal(old, new) {
+			if d != nil {
+				t.Fatalf("case %d: got a diff of equal inputs", i)
+			}
+			continue
+		}
+		patched, err := applyDiff(old, d)
+		if err != nil {
+			t.Fatalf("case %d: %v\nold: %q\nnew: %q\ndiff:\n%s", i, err, old, new, d)
+		}
+		if !bytes.Equal(patched, new) {
+			t.Fatalf("case %d: patch produced %q, want %q\ndiff:\n%s", i, patched, new, d)
+		}
+	}
+}
+
+func randomText(r *rand.Rand) []byte {
+	var b []byte
+	for range r.IntN(30) {
+		b = append(b, "abcde"[r.IntN(5)])
+		b = append(b, '\n')
+	}
+	if len(b) > 0 && r.IntN(4) == 0 {
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+func mutateText(r *rand.Rand, b []byte) []byte {
+	lines := splitLines(b)
+	var out []byte
+	for _, line := range lines {
+		switch r.IntN(6) {
+		case 0: // remove
+		case 1: // replace
+			out = append(out, "xyz"[r.IntN(3)], '\n')
+		case 2: // insert
+			out = append(out, "xyz"[r.IntN(3)], '\n')
+			out = append(out, line...)
+		default:
+			out = append(out, line...)
+		}
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+	}
+	if r.IntN(3) == 0 {
+		out = append(out, "tail\n"...)
+	}
+	if len(out) > 0 && r.IntN(4) == 0 {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// applyDiff applies the unified diff d to old.
+func applyDiff(old, d []byte) ([]byte, error) {
+	oldLines := splitLines(old)
+	lines := splitLines(d)
+	if len(lines) < 2 || !bytes.HasPrefix(lines[0], []byte("--- ")) || !bytes.HasPrefix(lines[1], []byte("+++ ")) {
+		return nil, fmt.Errorf("missing file header")
+	}
+	lines = lines[2:]
+	var out [][]byte
+	var next int // index of the next line of old to copy
+	for len(lines) > 0 {
+		var oldStart, oldCount, newStart, newCount int
+		if _, err := fmt.Sscanf(string(lines[0]), "@@ -%d,%d +%d,%d @@\n", &oldStart, &oldCount, &newStart, &newCount); err != nil {
+			return nil, fmt.Errorf("invalid hunk header %q: %v", lines[0], err)
+		}
+		lines = lines[1:]
+		start := oldStart - 1
+		if oldCount == 0 {
+			start = oldStart
+		}
+		if start < next {
+			return nil, fmt.Errorf("hunk at line %d overlaps the previous one", oldStart)
+		}
+		out = append(out, oldLines[next:start]...)
+		wantStart := len(out) + 1
+		if newCount == 0 {
+			wantStart = len(out)
+		}
+		if newStart != wantStart {
+			return nil, fmt.Errorf("hunk starts at new line %d, want %d", newStart, wantStart)
+		}
+		next = start
+		var gotOld, gotNew int
+		for len(lines) > 0 && !bytes.HasPrefix(lines[0], []byte("@@")) {
+			line := lines[0]
+			lines = lines[1:]
+			text := line[1:]
+			if len(lines) > 0 && bytes.Equal(lines[0], []byte("\\ No newline at end of file\n")) {
+				text = bytes.TrimSuffix(text, []byte("\n"))
+				lines = lines[1:]
+			}
+			switch line[0] {
+			case ' ', '-':
+				if next >= len(oldLines) || !bytes.Equal(oldLines[next], text) {
+					return nil, fmt.Errorf("line %d does not match %q", next+1, text)
+				}
+				next++
+				gotOld++
+				if line[0] == ' ' {
+					out = append(out, text)
+					gotNew++
+				}
+			case '+':
+				out = append(out, text)
+				gotNew++
+			default:
+				return nil, fmt.Errorf("invalid line %q", line)
+			}
+		}
+		if gotOld != oldCount || gotNew != newCount {
+			return nil, fmt.Errorf(
+				"hunk header counts %d,%d do not match the %d,%d lines of the hunk",
+				oldCount, newCount, gotOld, gotNew,
+			)
+		}
+	}
+	out = append(out, oldLines[next:]...)
+	return bytes.Join(out, nil), nil
+}
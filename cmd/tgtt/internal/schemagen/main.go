@@ -11,6 +11,7 @@
 	"github.com/antoniszymanski/collections-go/set"
 	"github.com/antoniszymanski/tgtt-go/cmd/tgtt/config"
 	"github.com/antoniszymanski/tgtt-go/cmd/tgtt/internal"
+	"github.com/antoniszymanski/tgtt-go/tgtt"
 	"github.com/invopop/jsonschema"
 )
 
@@ -26,6 +27,18 @@ func run() error {
 			schema.Version = ""
 			return schema
 		}
+		if t == reflect.TypeFor[tgtt.TypeMapping]() {
+			type RawTypeMapping tgtt.TypeMapping
+			object := r.ReflectFromType(reflect.TypeFor[RawTypeMapping]())
+			object.Version = ""
+			minLength := uint64(1)
+			return &jsonschema.Schema{
+				AnyOf: []*jsonschema.Schema{
+					{Type: "string", MinLength: &minLength},
+					object,
+				},
+			}
+		}
 		return nil
 	}
 	typ := reflect.TypeFor[config.Config]()
@@ -33,6 +46,11 @@ func run() error {
 		return err
 	}
 	schema := r.ReflectFromType(typ)
+	if presets, ok := schema.Properties.Get("presets"); ok {
+		for _, p := range tgtt.Presets() {
+			presets.Items.Enum = append(presets.Items.Enum, p.Name)
+		}
+	}
 	data, err := internal.MarshalJSON(schema)
 	if err != nil {
 		return err
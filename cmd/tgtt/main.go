@@ -9,6 +9,8 @@
 	Init     cmdInit     `cmd:""`
 	Schema   cmdSchema   `cmd:""`
 	Generate cmdGenerate `cmd:""`
+	Check    cmdCheck    `cmd:""`
+	Presets  cmdPresets  `cmd:""`
 	Version  cmdVersion  `cmd:""`
 }
 
@@ -0,0 +1,266 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	jsonc "github.com/DisposaBoy/JsonConfigReader"
+	"github.com/antoniszymanski/sanefmt-go"
+	"github.com/antoniszymanski/tgtt-go/cmd/tgtt/config"
+	"github.com/antoniszymanski/tgtt-go/tgtt"
+)
+
+func loadConfig(configPath string) (*config.Config, error) {
+	var f *os.File
+	var err error
+	if configPath != "-" {
+		f, err = os.Open(configPath)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() //nolint:errcheck
+	} else {
+		f = os.Stdin
+	}
+
+	data, err := io.ReadAll(jsonc.New(f))
+	if err != nil {
+		return nil, err
+	}
+	var cfg config.Config
+	if err = cfg.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	cfg.OutputPath = filepath.Clean(cfg.OutputPath)
+	return &cfg, nil
+}
+
+func transpile(ctx context.Context, cfg *config.Config) (tgtt.Package, error) {
+	var cacheDir string
+	if cfg.Cache {
+		var err error
+		if cacheDir, err = tgtt.DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	typeMappings := make(map[string]string)
+	importedTypeMappings := make(map[string]tgtt.TypeMapping)
+	for key, m := range cfg.TypeMappings {
+		if m.From == "" {
+			typeMappings[key] = m.Type
+		} else {
+			importedTypeMappings[key] = m
+		}
+	}
+	pkg, err := tgtt.TranspileContext(ctx, tgtt.TranspileOptions{
+		PrimaryPackage:       cfg.PrimaryPackage,
+		SecondaryPackages:    cfg.SecondaryPackages,
+		TypeMappings:         typeMappings,
+		ImportedTypeMappings: importedTypeMappings,
+		Presets:              cfg.Presets,
+		IncludeUnexported:    cfg.IncludeUnexported,
+		FallbackType:         cfg.FallbackType,
+		Declaration:          cfg.Declaration,
+		Layout:               tgtt.Layout(cfg.Layout),
+		Barrel:               tgtt.Barrel(cfg.Barrel),
+		ModuleNames:          cfg.ModuleNames,
+		Strict:               cfg.Strict,
+		StrictAllow:          cfg.StrictAllow,
+		CacheDir:             cacheDir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range pkg.Diagnostics() {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	return pkg, nil
+}
+
+// render renders pkg into memory. The returned files are keyed by their
+// slash-separated path relative to cfg.OutputPath.
+func render(cfg *config.Config, pkg tgtt.Package) (map[string][]byte, error) {
+	format := func(data []byte) ([]byte, error) {
+		if !cfg.Format {
+			return data, nil
+		}
+		return sanefmt.Format(bytes.NewReader(data))
+	}
+	if cfg.OutputMode == "single-file" {
+		data, err := format(pkg.RenderBundle())
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"index" + fileExtension(cfg): data}, nil
+	}
+
+	files := make(map[string][]byte, len(pkg))
+	var mu sync.Mutex
+	err := pkg.Render(tgtt.RenderOptions{
+		Imports: tgtt.ImportOptions{
+			TypeOnly:  cfg.ImportTypeOnly,
+			Extension: cfg.ImportExtension,
+			BasePath:  cfg.ImportBasePath,
+		},
+		Write: func(moduleName string, data []byte) (err error) {
+			if data, err = format(data); err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			files[pkg[moduleName].Path+fileExtension(cfg)] = data
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// writeOutput writes files into dir and returns the number of files that
+// changed. Changed files are first written to temporary files, which are
+// renamed over their targets only once all of them were written, and
+// files whose content is unchanged are left untouched. Finally, files
+// generated by a previous run that are no longer produced are removed.
+func writeOutput(dir string, files map[string][]byte) (changed int, err error) {
+	if err = os.MkdirAll(dir, 0750); err != nil {
+		return 0, err
+	}
+	oldManifest, err := readManifest(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	temps := make(map[string]string) // Keyed by target path
+	defer func() {
+		for _, temp := range temps {
+			os.Remove(temp) //nolint:errcheck
+		}
+	}()
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		data := files[name]
+		if old, err := os.ReadFile(target); err == nil && bytes.Equal(old, data) {
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+			return 0, err
+		}
+		temp, err := writeTemp(target, data)
+		if err != nil {
+			return 0, err
+		}
+		temps[target] = temp
+	}
+	for target, temp := range temps {
+		if err = os.Rename(temp, target); err != nil {
+			return 0, err
+		}
+		delete(temps, target)
+		changed++
+	}
+
+	names := slices.Collect(maps.Keys(files))
+	for _, name := range oldManifest.staleFiles(names) {
+		err = os.Remove(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return changed, err
+		}
+		// remove directories left empty, failing silently on non-empty ones
+		for d := path.Dir(name); d != "."; d = path.Dir(d) {
+			if os.Remove(filepath.Join(dir, filepath.FromSlash(d))) != nil {
+				break
+			}
+		}
+	}
+	return changed, writeManifest(dir, names)
+}
+
+func writeTemp(target string, data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()           //nolint:errcheck
+		os.Remove(f.Name()) //nolint:errcheck
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func fileExtension(cfg *config.Config) string {
+	switch {
+	case cfg.FileExtension != "":
+		return cfg.FileExtension
+	case cfg.Declaration:
+		return ".d.ts"
+	default:
+		return ".ts"
+	}
+}
+
+// manifestName is the name of the file, stored in the output directory,
+// that lists the files generated by tgtt.
+const manifestName = ".tgtt-manifest.json"
+
+type manifest struct {
+	Files []string `json:"files"` // Slash-separated, relative to the output directory
+}
+
+func readManifest(dir string) (manifest, error) {
+	var m manifest
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	} else if err != nil {
+		return m, err
+	}
+	if err = json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", manifestName, err)
+	}
+	return m, nil
+}
+
+func writeManifest(dir string, files []string) error {
+	m := manifest{Files: slices.Sorted(slices.Values(files))}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	return os.WriteFile(filepath.Join(dir, manifestName), data, 0600)
+}
+
+// staleFiles returns the files listed in m that are not in files.
+func (m manifest) staleFiles(files []string) []string {
+	var stale []string
+	for _, name := range m.Files {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			continue // never touch files outside the output directory
+		}
+		if !slices.Contains(files, name) {
+			stale = append(stale, name)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Barrel determines how the index module re-exports secondary packages.
+type Barrel string
+
+const (
+	BarrelNone Barrel = "none"
+	// BarrelNamespace re-exports every secondary package as a namespace:
+	// `export * as name from "./name"`.
+	BarrelNamespace Barrel = "namespace"
+	// BarrelFlatten re-exports the definitions of every secondary package
+	// directly: `export type { X } from "./name"`.
+	BarrelFlatten Barrel = "flatten"
+)
+
+// Reexport re-exports definitions of Module from the module it belongs to.
+type Reexport struct {
+	Module    *Module
+	Namespace string   // If set, rendered as `export * as Namespace from`
+	Types     []string // Otherwise rendered as `export type { Types } from`
+	Values    []string // and `export { Values } from`
+}
+
+func (t *transpiler) addBarrel(barrel Barrel) error {
+	if barrel == "" || barrel == BarrelNone {
+		return nil
+	}
+	index := t.modules.Index()
+	exported := make(map[string]string) // Keyed by name, values are Go paths
+	for name := range index.Defs.Keys() {
+		exported[name] = index.GoPath
+	}
+	export := func(name string, mod *Module) error {
+		if goPath, ok := exported[name]; ok {
+			return fmt.Errorf("barrel: %s is exported by both %s and %s", name, goPath, mod.GoPath)
+		}
+		exported[name] = mod.GoPath
+		return nil
+	}
+	for _, pkg := range t.secondaryPkgs {
+		mod := t.modules[pkg.Name]
+		r := Reexport{Module: mod}
+		switch barrel {
+		case BarrelNamespace:
+			r.Namespace = pkg.Name
+			if err := export(r.Namespace, mod); err != nil {
+				return err
+			}
+		case BarrelFlatten:
+			for name, def := range mod.Defs.AllFromFront() {
+				if err := export(name, mod); err != nil {
+					return err
+				}
+				if strings.HasPrefix(def, "export type ") {
+					r.Types = append(r.Types, name)
+				} else {
+					r.Values = append(r.Values, name)
+				}
+			}
+		default:
+			return fmt.Errorf("unknown barrel %q", barrel)
+		}
+		index.Reexports = append(index.Reexports, r)
+	}
+	return nil
+}
@@ -0,0 +1,417 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/antoniszymanski/collections-go/orderedset"
+	"github.com/antoniszymanski/collections-go/set"
+	"github.com/antoniszymanski/loadpackage-go"
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion must be incremented whenever the output of Transpile or the
+// format of cache entries changes.
+const cacheVersion = 2
+
+const (
+	cacheMaxAge       = 7 * 24 * time.Hour // Entries unused for longer are removed
+	cacheTrimInterval = 24 * time.Hour     // How often the cache is trimmed
+)
+
+// DefaultCacheDir returns the default directory of the on-disk cache.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tgtt"), nil
+}
+
+// pkgCache is an on-disk cache of the definitions emitted for each package.
+// Entries are keyed by the sources of a package and the keys of its
+// dependencies, so editing a package only invalidates the entries of the
+// package and of the packages that depend on it.
+type pkgCache struct {
+	dir  string
+	opts []byte            // Hash of the options
+	keys map[string][]byte // Keyed by package path
+}
+
+func newPkgCache(dir string, opts TranspileOptions) (*pkgCache, error) {
+	// names only select the definitions of their own package, so they are
+	// part of the entry keys instead
+	opts.PrimaryPackage.Names = set.Set[string]{}
+	opts.SecondaryPackages = slices.Clone(opts.SecondaryPackages)
+	for i := range opts.SecondaryPackages {
+		opts.SecondaryPackages[i].Names = set.Set[string]{}
+	}
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "tgtt %d %s\n", cacheVersion, runtime.Version())
+	h.Write(data)
+	return &pkgCache{dir: dir, opts: h.Sum(nil), keys: make(map[string][]byte)}, nil
+}
+
+// listMode is the mode in which packages are loaded to compute cache keys.
+const listMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedModule
+
+// cachedPackage is the cache state of a package to transpile.
+type cachedPackage struct {
+	key   string      // Empty if the package cannot be cached
+	entry *cacheEntry // Nil if the entry misses
+}
+
+// lookupCache looks up the entries of the listed packages to transpile,
+// whose selected names are given by names, and type-checks the packages
+// whose entries miss. The type-checked packages replace the listed ones.
+// The result is keyed by package path.
+func (t *transpiler) lookupCache(cfg *packages.Config, names []set.Set[string]) (map[string]cachedPackage, error) {
+	pkgs := append([]*packages.Package{t.primaryPkg}, t.secondaryPkgs...)
+	cached := make(map[string]cachedPackage, len(pkgs))
+	var missed []string
+	for i, pkg := range pkgs {
+		var c cachedPackage
+		// the cache is best-effort
+		if key, err := t.cache.entryKey(pkg, names[i]); err == nil {
+			c.key = key
+			c.entry, _ = t.cache.load(key)
+		}
+		if c.entry == nil {
+			missed = append(missed, pkg.PkgPath)
+		}
+		cached[pkg.PkgPath] = c
+	}
+	if len(missed) == 0 {
+		return cached, nil
+	}
+
+	roots, err := packages.Load(cfg, missed...)
+	if err != nil {
+		return nil, err
+	}
+	typed := make(map[string]*packages.Package, len(roots))
+	for _, root := range roots {
+		if err = loadpackage.Validate(root); err != nil {
+			return nil, err
+		}
+		typed[root.PkgPath] = root
+	}
+	if pkg, ok := typed[t.primaryPkg.PkgPath]; ok {
+		t.primaryPkg = pkg
+	}
+	for i, pkg := range t.secondaryPkgs {
+		if pkg, ok := typed[pkg.PkgPath]; ok {
+			t.secondaryPkgs[i] = pkg
+		}
+	}
+	return cached, nil
+}
+
+// entryKey returns the key of the definitions of pkg selected by names.
+func (c *pkgCache) entryKey(pkg *packages.Package, names set.Set[string]) (string, error) {
+	key, err := c.pkgKey(pkg)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(c.opts)
+	h.Write(key)
+	for _, name := range slices.Sorted(names.All()) {
+		fmt.Fprintf(h, "name %s\n", name)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// pkgKey hashes the sources of pkg together with the keys of its imports.
+// Files in GOROOT are covered by the Go version and files in the module
+// cache by the module version, so neither is read.
+func (c *pkgCache) pkgKey(pkg *packages.Package) ([]byte, error) {
+	if key, ok := c.keys[pkg.PkgPath]; ok {
+		return key, nil
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "package %s %s\n", pkg.PkgPath, pkg.Name)
+	var versioned bool
+	if m := pkg.Module; m != nil && m.Version != "" && m.Replace == nil {
+		fmt.Fprintf(h, "module %s@%s\n", m.Path, m.Version)
+		versioned = true
+	}
+	goroot := filepath.Join(build.Default.GOROOT, "src") + string(filepath.Separator)
+	for _, file := range pkg.GoFiles {
+		if versioned || strings.HasPrefix(file, goroot) {
+			fmt.Fprintf(h, "%s\n", file)
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "%s %x\n", file, sha256.Sum256(data))
+	}
+	for _, path := range slices.Sorted(maps.Keys(pkg.Imports)) {
+		key, err := c.pkgKey(pkg.Imports[path])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "import %s %x\n", path, key)
+	}
+	key := h.Sum(nil)
+	c.keys[pkg.PkgPath] = key
+	return key, nil
+}
+
+// cacheEntry holds the definitions emitted for a package, including the
+// definitions of other modules that they reference.
+type cacheEntry struct {
+	Modules     map[string]cachedModule // Keyed by module name
+	Defs        []*defRecord
+	Diagnostics []Diagnostic // Reported outside of definitions
+}
+
+type cachedModule struct {
+	GoPath  string
+	Path    string
+	GoFiles []string
+}
+
+// defRecord is a definition together with everything that emitting it
+// added to its module.
+type defRecord struct {
+	Module      string // Module name
+	Name        string
+	PkgPath     string // Package path of the Go object
+	ObjName     string // Name of the Go object
+	Pos         token.Position
+	Def         string   // Empty if the object was dropped
+	Imports     []string // Module names
+	TypeImports []cachedTypeImport
+	Diagnostics []Diagnostic
+	Refs        []defRef // Definitions referenced by this one
+}
+
+type cachedTypeImport struct {
+	From string
+	Type string
+}
+
+type defRef struct {
+	Module string // Module name
+	Name   string
+}
+
+// valid reports whether every module referenced by e is part of it.
+func (e *cacheEntry) valid() bool {
+	for _, rec := range e.Defs {
+		if _, ok := e.Modules[rec.Module]; !ok {
+			return false
+		}
+		for _, name := range rec.Imports {
+			if _, ok := e.Modules[name]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *pkgCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// load returns the entry cached under key, if any, and marks it as used.
+func (c *pkgCache) load(key string) (*cacheEntry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e cacheEntry
+	if err = json.Unmarshal(data, &e); err != nil || !e.valid() {
+		return nil, false
+	}
+	now := time.Now()
+	os.Chtimes(c.path(key), now, now) //nolint:errcheck
+	return &e, true
+}
+
+// store caches e under key.
+func (c *pkgCache) store(key string, e *cacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(c.dir, 0750); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()           //nolint:errcheck
+		os.Remove(f.Name()) //nolint:errcheck
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return err
+	}
+	return os.Rename(f.Name(), c.path(key))
+}
+
+// trim removes the entries that were not used for cacheMaxAge. It does
+// nothing if the cache was trimmed less than cacheTrimInterval ago.
+func (c *pkgCache) trim() error {
+	marker := filepath.Join(c.dir, "trim.txt")
+	now := time.Now()
+	if info, err := os.Stat(marker); err == nil && now.Sub(info.ModTime()) < cacheTrimInterval {
+		return nil
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if ext := filepath.Ext(entry.Name()); ext != ".json" && ext != ".tmp" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) > cacheMaxAge {
+			os.Remove(filepath.Join(c.dir, entry.Name())) //nolint:errcheck
+		}
+	}
+	return os.WriteFile(marker, []byte(now.Format(time.RFC3339)), 0600)
+}
+
+// cacheEntry collects the definitions reachable from refs, which were
+// emitted by the worker of the package of module.
+func (t *transpiler) cacheEntry(module string, refs []defRef, diags []Diagnostic) *cacheEntry {
+	e := &cacheEntry{Modules: make(map[string]cachedModule), Diagnostics: diags}
+	addModule := func(name string) {
+		if _, ok := e.Modules[name]; !ok {
+			mod := t.modules[name]
+			e.Modules[name] = cachedModule{GoPath: mod.GoPath, Path: mod.Path, GoFiles: mod.GoFiles}
+		}
+	}
+	addModule(module)
+	seen := set.New[defRef](0)
+	stack := slices.Clone(refs)
+	for len(stack) > 0 {
+		ref := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen.Contains(ref) {
+			continue
+		}
+		seen.Insert(ref)
+		rec := t.shared.records[claimKey{t.modules[ref.Module], ref.Name}]
+		if rec == nil {
+			continue
+		}
+		e.Defs = append(e.Defs, rec)
+		addModule(rec.Module)
+		for _, name := range rec.Imports {
+			addModule(name)
+		}
+		stack = append(stack, rec.Refs...)
+	}
+	return e
+}
+
+// replay adds the definitions of e to the modules, as if the package of
+// mod was transpiled. It must be called before the workers start.
+func (t *transpiler) replay(e *cacheEntry, mod *Module) error {
+	for _, name := range slices.Sorted(maps.Keys(e.Modules)) {
+		m := e.Modules[name]
+		if name == "$builtin" {
+			t.modules.builtin()
+			continue
+		}
+		if existing, ok := t.modules[name]; !ok {
+			added := newModule(m.GoPath)
+			added.Path = m.Path
+			added.GoFiles = m.GoFiles
+			t.modules[name] = added
+			t.report(Event{Kind: EventModuleStarted, PkgPath: m.GoPath, Module: name})
+		} else if existing.GoPath != m.GoPath {
+			return fmt.Errorf(
+				"module name %q is used by both %s and %s; give one of them a different name in module_names",
+				name, existing.GoPath, m.GoPath,
+			)
+		}
+	}
+	for _, rec := range e.Defs {
+		defMod := t.modules[rec.Module]
+		if !t.claim(defMod, rec.Name, objectKey{rec.PkgPath, rec.ObjName}, rec.Pos) {
+			continue
+		}
+		t.shared.records[claimKey{defMod, rec.Name}] = rec
+		for _, name := range rec.Imports {
+			defMod.Imports.Set(name, t.modules[name])
+		}
+		for _, x := range rec.TypeImports {
+			names, ok := defMod.TypeImports.Get(x.From)
+			if !ok {
+				names = orderedset.New[string](0)
+				defMod.TypeImports.Set(x.From, names)
+			}
+			names.Insert(x.Type)
+		}
+		defMod.Diagnostics = append(defMod.Diagnostics, rec.Diagnostics...)
+		if rec.Def != "" {
+			defMod.Defs.Set(rec.Name, rec.Def)
+			t.report(Event{Kind: EventDefinitionEmitted, PkgPath: defMod.GoPath, Module: rec.Module, Name: rec.Name})
+		}
+	}
+	mod.Diagnostics = append(mod.Diagnostics, e.Diagnostics...)
+	return nil
+}
+
+// record starts the cache record of the definition name of obj in mod.
+func (t *transpiler) record(mod *Module, module, name string, obj types.Object) *defRecord {
+	rec := &defRecord{
+		Module:  module,
+		Name:    name,
+		PkgPath: obj.Pkg().Path(),
+		ObjName: obj.Name(),
+		Pos:     t.objectPos(obj),
+	}
+	t.shared.mu.Lock()
+	t.shared.records[claimKey{mod, name}] = rec
+	t.shared.mu.Unlock()
+	return rec
+}
+
+// recordRef records that the definition name of module was referenced,
+// either by the definition being transpiled or by the worker itself.
+func (t *transpiler) recordRef(module, name string) {
+	if t.cache == nil {
+		return
+	}
+	ref := defRef{Module: module, Name: name}
+	if t.rec != nil {
+		t.rec.Refs = append(t.rec.Refs, ref)
+	} else {
+		t.touched = append(t.touched, ref)
+	}
+}
@@ -0,0 +1,211 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityWarning Severity = iota
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Diagnostic codes.
+const (
+	CodeFallbackType        = "fallback-type"        // A type was emitted as the fallback type
+	CodeErrorType           = "error-type"           // An error was emitted as any
+	CodeUnsupportedConstant = "unsupported-constant" // A constant was dropped
+	CodeSkippedType         = "skipped-type"         // A type declaration was dropped
+	CodeLossyMapping        = "lossy-mapping"        // A type was mapped to any or unknown
+	CodeEmptyEnum           = "empty-enum"           // An enum has no representable constants
+	CodeAnyType             = "any-type"             // An empty interface was emitted as any; strict mode only
+)
+
+// isLossy reports whether code denotes a type that lost its shape in
+// translation. Strict mode fails on such diagnostics.
+func isLossy(code string) bool {
+	switch code {
+	case CodeFallbackType, CodeErrorType, CodeLossyMapping, CodeAnyType:
+		return true
+	default:
+		return false
+	}
+}
+
+// Diagnostic reports a problem found while transpiling.
+type Diagnostic struct {
+	Pos token.Position
+	// Path of the affected definition or field, e.g. index.Order.Items[].Meta.
+	// Elements of arrays and slices are denoted by [] and values of maps by {}.
+	Path     string
+	Severity Severity
+	Code     string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	if d.Pos.IsValid() {
+		sb.WriteString(d.Pos.String())
+		sb.WriteString(": ")
+	}
+	sb.WriteString(d.Severity.String())
+	sb.WriteString(": ")
+	if d.Path != "" {
+		sb.WriteString(d.Path)
+		sb.WriteString(": ")
+	}
+	sb.WriteString(d.Message)
+	if d.Code != "" {
+		sb.WriteString(" [")
+		sb.WriteString(d.Code)
+		sb.WriteByte(']')
+	}
+	return sb.String()
+}
+
+// Diagnostics returns the diagnostics of all modules sorted by position.
+func (p Package) Diagnostics() []Diagnostic {
+	var diags []Diagnostic
+	for _, mod := range p {
+		diags = append(diags, mod.Diagnostics...)
+	}
+	sortDiagnostics(diags)
+	return diags
+}
+
+func sortDiagnostics(diags []Diagnostic) {
+	slices.SortFunc(diags, func(a, b Diagnostic) int {
+		if c := comparePositions(a.Pos, b.Pos); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Path, b.Path); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Code, b.Code); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Message, b.Message)
+	})
+}
+
+// StrictError is returned by Transpile in strict mode when some types
+// were translated lossily.
+type StrictError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *StrictError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "strict mode: %d lossy translation(s):", len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		sb.WriteString("\n\t")
+		sb.WriteString(d.String())
+	}
+	return sb.String()
+}
+
+// checkStrict promotes lossy diagnostics whose path matches none of the
+// allow patterns to errors, and reports them as a *StrictError.
+func (t *transpiler) checkStrict(allow []string) error {
+	patterns := make([]*regexp.Regexp, len(allow))
+	for i, pattern := range allow {
+		patterns[i], _ = compileGlob(pattern)
+	}
+	allowed := func(path string) bool {
+		return slices.ContainsFunc(patterns, func(re *regexp.Regexp) bool {
+			return re.MatchString(path)
+		})
+	}
+	for _, mod := range t.modules {
+		for i, d := range mod.Diagnostics {
+			if isLossy(d.Code) && !allowed(d.Path) {
+				mod.Diagnostics[i].Severity = SeverityError
+			}
+		}
+	}
+	var errs []Diagnostic
+	for _, d := range t.modules.Diagnostics() {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) > 0 {
+		return &StrictError{Diagnostics: errs}
+	}
+	return nil
+}
+
+// setPath sets the path reported by diagnostics to the definition obj and
+// returns a function that restores the previous one.
+func (t *transpiler) setPath(obj types.Object) (restore func()) {
+	prev := t.path
+	t.path = []string{t.packages[obj.Pkg().Path()].Name, ".", t.tsName(obj)}
+	return func() { t.path = prev }
+}
+
+// pushPath appends elem to the path reported by diagnostics and returns a
+// function that removes it.
+func (t *transpiler) pushPath(elem ...string) (pop func()) {
+	n := len(t.path)
+	t.path = append(t.path, elem...)
+	return func() { t.path = t.path[:n] }
+}
+
+// setPos sets the position reported by diagnostics and returns a function
+// that restores the previous one.
+func (t *transpiler) setPos(pkg *types.Package, pos token.Pos) (restore func()) {
+	prev := t.pos
+	if pkg != nil {
+		if p, ok := t.packages[pkg.Path()]; ok {
+			t.pos = p.Fset.Position(pos)
+		}
+	}
+	return func() { t.pos = prev }
+}
+
+// setInConstraint sets whether a type parameter constraint is being
+// transpiled and returns a function that restores the previous value.
+func (t *transpiler) setInConstraint(v bool) (restore func()) {
+	prev := t.inConstraint
+	t.inConstraint = v
+	return func() { t.inConstraint = prev }
+}
+
+func (t *transpiler) warn(mod *Module, code, format string, args ...any) {
+	t.shared.mu.Lock()
+	defer t.shared.mu.Unlock()
+	d := Diagnostic{
+		Pos:      t.pos,
+		Path:     strings.Join(t.path, ""),
+		Severity: SeverityWarning,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	mod.Diagnostics = append(mod.Diagnostics, d)
+	if t.rec != nil {
+		t.rec.Diagnostics = append(t.rec.Diagnostics, d)
+	} else if t.cache != nil {
+		t.diags = append(t.diags, d)
+	}
+}
@@ -0,0 +1,114 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// directives holds the //tgtt: comment directives of a declaration:
+//   - //tgtt:export selects the declaration regardless of PackageOptions.Names
+//     and IncludeUnexported
+//   - //tgtt:ignore excludes the declaration from selection; it is still
+//     emitted when referenced by another definition
+//   - //tgtt:name=Foo emits the declaration under the name Foo
+//   - //tgtt:type=... replaces the transpiled type of a type declaration
+//   - //tgtt:enum emits a type declaration as the union of its constants
+type directives struct {
+	Export bool
+	Ignore bool
+	Name   string
+	Type   string
+	Enum   bool
+}
+
+type objectKey struct {
+	pkgPath string
+	name    string
+}
+
+func keyOf(obj types.Object) objectKey {
+	var key objectKey
+	if pkg := obj.Pkg(); pkg != nil {
+		key.pkgPath = pkg.Path()
+	}
+	key.name = obj.Name()
+	return key
+}
+
+func (t *transpiler) directivesOf(obj types.Object) directives {
+	return t.directives[keyOf(obj)]
+}
+
+func (t *transpiler) collectDirectives(pkg *packages.Package) {
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || (decl.Tok != token.TYPE && decl.Tok != token.CONST) {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				var d directives
+				parseDirectives(&d, decl.Doc)
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					parseDirectives(&d, spec.Doc)
+					t.setDirectives(pkg, spec.Name, d)
+				case *ast.ValueSpec:
+					parseDirectives(&d, spec.Doc)
+					for _, name := range spec.Names {
+						t.setDirectives(pkg, name, d)
+					}
+				}
+			}
+		}
+	}
+}
+
+func (t *transpiler) setDirectives(pkg *packages.Package, ident *ast.Ident, d directives) {
+	if d == (directives{}) {
+		return
+	}
+	if obj := pkg.TypesInfo.Defs[ident]; obj != nil {
+		t.directives[keyOf(obj)] = d
+	}
+}
+
+func parseDirectives(d *directives, doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+	for _, c := range doc.List {
+		text, ok := strings.CutPrefix(c.Text, "//tgtt:")
+		if !ok {
+			continue
+		}
+		key, value, _ := strings.Cut(strings.TrimSpace(text), "=")
+		switch key {
+		case "export":
+			d.Export = true
+		case "ignore":
+			d.Ignore = true
+		case "name":
+			d.Name = strings.TrimSpace(value)
+		case "type":
+			d.Type = strings.TrimSpace(value)
+		case "enum":
+			d.Enum = true
+		}
+	}
+}
+
+// tsName returns the name under which obj is emitted.
+func (t *transpiler) tsName(obj types.Object) string {
+	if name := t.directivesOf(obj).Name; name != "" {
+		return tsIdent(name)
+	}
+	return tsIdent(obj.Name())
+}
@@ -121,14 +121,21 @@ func transpileStructType(dst []byte, expr *ast.StructType) (_ []byte, err error)
 		dst = append(dst, ' ')
 	}
 	for i, field := range s.Fields {
+		if field.Readonly {
+			dst = append(dst, "readonly "...)
+		}
 		dst = strconv.AppendQuote(dst, field.Name)
 		if field.Optional {
 			dst = append(dst, '?')
 		}
 		dst = append(dst, ": "...)
-		dst, err = transpileExpr(dst, field.Type)
-		if err != nil {
-			return nil, err
+		if field.TypeOverride != "" {
+			dst = append(dst, field.TypeOverride...)
+		} else {
+			dst, err = transpileExpr(dst, field.Type)
+			if err != nil {
+				return nil, err
+			}
 		}
 		if i < len(s.Fields)-1 {
 			dst = append(dst, ';')
@@ -138,8 +145,12 @@ func transpileStructType(dst []byte, expr *ast.StructType) (_ []byte, err error)
 	dst = append(dst, '}')
 	for _, embedded := range s.Embedded {
 		dst = append(dst, " & "...)
+		if embedded.TypeOverride != "" {
+			dst = append(dst, embedded.TypeOverride...)
+			continue
+		}
 		i := len(dst)
-		dst, err = transpileExpr(dst, embedded)
+		dst, err = transpileExpr(dst, embedded.Type)
 		if err != nil {
 			return nil, err
 		}
@@ -181,7 +192,7 @@ func parseStructType(expr *ast.StructType) structInfo[ast.Expr] {
 				continue
 			}
 			if f.Name == "" {
-				s.Embedded = append(s.Embedded, f.Type)
+				s.Embedded = append(s.Embedded, f)
 			} else {
 				s.Fields = append(s.Fields, f)
 			}
@@ -0,0 +1,54 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/antoniszymanski/collections-go/set"
+)
+
+// reservedIdents contains the names that cannot be used as TypeScript
+// identifiers for modules, types or constants.
+var reservedIdents = set.From(
+	// reserved words
+	"break", "case", "catch", "class", "const", "continue", "debugger",
+	"default", "delete", "do", "else", "enum", "export", "extends", "false",
+	"finally", "for", "function", "if", "import", "in", "instanceof", "new",
+	"null", "return", "super", "switch", "this", "throw", "true", "try",
+	"typeof", "var", "void", "while", "with",
+	// strict mode reserved words
+	"arguments", "await", "eval", "implements", "interface", "let",
+	"package", "private", "protected", "public", "static", "yield",
+	// predefined type names
+	"any", "bigint", "boolean", "never", "number", "object", "string",
+	"symbol", "undefined", "unknown",
+)
+
+// tsIdent turns name into a valid TypeScript identifier. Characters that
+// cannot appear in an identifier are replaced with '_', and reserved
+// words get a '_' suffix.
+func tsIdent(name string) string {
+	if name == "" {
+		return "_"
+	}
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '$' || unicode.IsLetter(r):
+		case i > 0 && (unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Pc)):
+		case i == 0 && unicode.IsDigit(r):
+			sb.WriteByte('_')
+		default:
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	name = sb.String()
+	if reservedIdents.Contains(name) {
+		name += "_"
+	}
+	return name
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// pkgIndex holds the transpilable definitions of a package.
+type pkgIndex struct {
+	defs        []types.Object               // Sorted by position
+	unsupported []types.Object               // Package-level objects that cannot be transpiled, sorted by position
+	byName      map[string][]types.Object    // Keyed by object name
+	consts      map[objectKey][]*types.Const // Keyed by named type
+}
+
+// indexOf returns the index of pkg, building it on first use.
+func (t *transpiler) indexOf(pkg *packages.Package) *pkgIndex {
+	t.shared.mu.Lock()
+	defer t.shared.mu.Unlock()
+	if idx, ok := t.indexes[pkg.PkgPath]; ok {
+		return idx
+	}
+	idx := &pkgIndex{
+		defs:        sortedDefs(pkg, isTranspilable),
+		unsupported: sortedDefs(pkg, isUnsupported),
+		byName:      make(map[string][]types.Object),
+		consts:      make(map[objectKey][]*types.Const),
+	}
+	for _, obj := range idx.defs {
+		idx.byName[obj.Name()] = append(idx.byName[obj.Name()], obj)
+		c, ok := obj.(*types.Const)
+		if !ok {
+			continue
+		}
+		if typ, ok := c.Type().(*types.Named); ok {
+			key := keyOf(typ.Obj())
+			idx.consts[key] = append(idx.consts[key], c)
+		}
+	}
+	t.indexes[pkg.PkgPath] = idx
+	return idx
+}
@@ -0,0 +1,132 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/antoniszymanski/loadpackage-go"
+	"golang.org/x/tools/go/packages"
+)
+
+// loadPackages loads the packages of opts with a single packages.Load
+// call, so that shared dependencies are type-checked once and all packages
+// share one FileSet and type universe. The i-th element of the result holds
+// the packages matched by opts[i] that are not excluded by it, sorted by
+// package path. A pattern without wildcards matches exactly one package.
+func loadPackages(cfg *packages.Config, opts []PackageOptions) ([][]*packages.Package, error) {
+	queries := make([]string, len(opts))
+	for i, o := range opts {
+		if err := checkPattern(o.Path); err != nil {
+			return nil, fmt.Errorf("%s: %w", o.Path, err)
+		}
+		queries[i] = "pattern=" + o.Path
+	}
+	roots, err := packages.Load(cfg, queries...)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := make([][]*packages.Package, len(opts))
+	for i, o := range opts {
+		match, err := patternMatcher(cfg, o.Path)
+		if err != nil {
+			return nil, err
+		}
+		excludes := make([]func(*packages.Package) bool, len(o.Exclude))
+		for j, pattern := range o.Exclude {
+			if excludes[j], err = patternMatcher(cfg, pattern); err != nil {
+				return nil, err
+			}
+		}
+		for _, root := range roots {
+			if !match(root) || slices.ContainsFunc(excludes, func(exclude func(*packages.Package) bool) bool {
+				return exclude(root)
+			}) {
+				continue
+			}
+			if err = loadpackage.Validate(root); err != nil {
+				return nil, err
+			}
+			pkgs[i] = append(pkgs[i], root)
+			if !isWildcard(o.Path) {
+				break
+			}
+		}
+		if len(pkgs[i]) == 0 {
+			// surface the load errors of the pattern, if any
+			for _, root := range roots {
+				if err = loadpackage.Validate(root); err != nil {
+					return nil, err
+				}
+			}
+			return nil, fmt.Errorf("%s: no package matched the pattern", o.Path)
+		}
+		slices.SortFunc(pkgs[i], func(a, b *packages.Package) int {
+			return strings.Compare(a.PkgPath, b.PkgPath)
+		})
+	}
+	return pkgs, nil
+}
+
+func checkPattern(pattern string) error {
+	// https://pkg.go.dev/cmd/go#hdr-Package_lists_and_patterns
+	switch pattern {
+	case "main", "all", "std", "cmd", "tool":
+		return errors.New("pattern cannot be a reserved name")
+	}
+	return nil
+}
+
+func isWildcard(pattern string) bool {
+	return strings.Contains(pattern, "...")
+}
+
+// patternMatcher returns a function reporting whether pkg is matched by
+// pattern. Relative and absolute patterns are matched against the package
+// directory, import path patterns against the package path. As in the go
+// command, "..." matches any string, and a trailing "/..." also matches the
+// empty string.
+func patternMatcher(cfg *packages.Config, pattern string) (func(pkg *packages.Package) bool, error) {
+	if !isPathPattern(pattern) {
+		re := compilePattern(pattern)
+		return func(pkg *packages.Package) bool { return re.MatchString(pkg.PkgPath) }, nil
+	}
+	dir := pattern
+	if !filepath.IsAbs(dir) {
+		base := cfg.Dir
+		if base == "" {
+			var err error
+			if base, err = filepath.Abs("."); err != nil {
+				return nil, err
+			}
+		}
+		dir = filepath.Join(base, dir)
+	}
+	re := compilePattern(filepath.ToSlash(filepath.Clean(dir)))
+	return func(pkg *packages.Package) bool {
+		return pkg.Dir != "" && re.MatchString(filepath.ToSlash(pkg.Dir))
+	}, nil
+}
+
+func compilePattern(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\.\.\.`, `.*`)
+	if rest, ok := strings.CutSuffix(expr, `/.*`); ok {
+		expr = rest + `(/.*)?`
+	}
+	return regexp.MustCompile("^" + expr + "$")
+}
+
+func isPathPattern(pattern string) bool {
+	return filepath.IsAbs(pattern) ||
+		pattern == "." || pattern == ".." ||
+		strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") ||
+		strings.HasPrefix(pattern, `.\`) || strings.HasPrefix(pattern, `..\`)
+}
@@ -0,0 +1,100 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Preset is a named set of type mappings for well-known Go types.
+type Preset struct {
+	Name        string
+	Description string
+	Mappings    map[string]TypeMapping
+}
+
+var presets = []Preset{
+	{
+		Name:        "stdlib",
+		Description: "time, encoding/json, math/big and net types",
+		Mappings: map[string]TypeMapping{
+			"time.Time":                {Type: "string"},
+			"time.Duration":            {Type: "number"},
+			"encoding/json.RawMessage": {Type: "any"},
+			"encoding/json.Number":     {Type: "number"},
+			"math/big.Int":             {Type: "number"},
+			"math/big.Float":           {Type: "string"},
+			"math/big.Rat":             {Type: "string"},
+			"net.IP":                   {Type: "string"},
+			"net/netip.Addr":           {Type: "string"},
+			"net/netip.AddrPort":       {Type: "string"},
+			"net/netip.Prefix":         {Type: "string"},
+		},
+	},
+	{
+		Name:        "google/uuid",
+		Description: "github.com/google/uuid types",
+		Mappings: map[string]TypeMapping{
+			"github.com/google/uuid.UUID":     {Type: "string"},
+			"github.com/google/uuid.NullUUID": {Type: "string | null"},
+		},
+	},
+	{
+		Name:        "shopspring/decimal",
+		Description: "github.com/shopspring/decimal types",
+		Mappings: map[string]TypeMapping{
+			"github.com/shopspring/decimal.Decimal":     {Type: "string"},
+			"github.com/shopspring/decimal.NullDecimal": {Type: "string | null"},
+		},
+	},
+	{
+		Name:        "mongo-driver",
+		Description: "MongoDB Go driver (v1 and v2) BSON types",
+		Mappings: map[string]TypeMapping{
+			"go.mongodb.org/mongo-driver/bson/primitive.ObjectID": {Type: "string"},
+			"go.mongodb.org/mongo-driver/v2/bson.ObjectID":        {Type: "string"},
+		},
+	},
+}
+
+// Presets returns all built-in presets sorted by name.
+func Presets() []Preset {
+	out := make([]Preset, 0, len(presets))
+	for _, p := range presets {
+		p.Mappings = maps.Clone(p.Mappings)
+		out = append(out, p)
+	}
+	slices.SortFunc(out, func(a, b Preset) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out
+}
+
+// LookupPreset returns the built-in preset with the given name.
+func LookupPreset(name string) (Preset, bool) {
+	i := slices.IndexFunc(presets, func(p Preset) bool {
+		return p.Name == name
+	})
+	if i == -1 {
+		return Preset{}, false
+	}
+	p := presets[i]
+	p.Mappings = maps.Clone(p.Mappings)
+	return p, true
+}
+
+func presetTypeMappings(names []string) (map[string]TypeMapping, error) {
+	mappings := make(map[string]TypeMapping)
+	for _, name := range names {
+		p, ok := LookupPreset(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q", name)
+		}
+		maps.Copy(mappings, p.Mappings)
+	}
+	return mappings, nil
+}
@@ -0,0 +1,46 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import "fmt"
+
+type EventKind int
+
+const (
+	EventPackageLoaded     EventKind = iota // A package and its dependencies were loaded
+	EventModuleStarted                      // A module was created for a package
+	EventDefinitionEmitted                  // A definition was added to a module
+	EventPackageCached                      // The definitions of a package were reused from the cache
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventPackageLoaded:
+		return "package loaded"
+	case EventModuleStarted:
+		return "module started"
+	case EventDefinitionEmitted:
+		return "definition emitted"
+	case EventPackageCached:
+		return "package cached"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// Event describes the progress of Transpile.
+type Event struct {
+	Kind    EventKind
+	PkgPath string
+	Module  string // Empty for EventPackageLoaded
+	Name    string // Name of the definition for EventDefinitionEmitted
+}
+
+func (t *transpiler) report(e Event) {
+	if t.progress != nil {
+		t.shared.progressMu.Lock()
+		defer t.shared.progressMu.Unlock()
+		t.progress(e)
+	}
+}
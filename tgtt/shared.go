@@ -0,0 +1,151 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"cmp"
+	"fmt"
+	"go/token"
+	"go/types"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/antoniszymanski/collections-go/orderedset"
+	"github.com/elliotchance/orderedmap/v3"
+)
+
+// shared holds the state that the workers of Transpile share. Modules,
+// their contents and the package indexes are guarded by mu.
+type shared struct {
+	mu         sync.Mutex
+	claims     map[claimKey]claim
+	records    map[claimKey]*defRecord // Cache records of the claimed definitions
+	err        error                   // First error of the workers
+	progressMu sync.Mutex              // Serializes progress callbacks
+}
+
+type claimKey struct {
+	mod  *Module
+	name string
+}
+
+type claim struct {
+	obj objectKey
+	pos token.Position // Source position of obj
+}
+
+// fork returns a copy of t for a worker. The copy shares modules and the
+// package state with t, but has its own diagnostic position and path and
+// its own cache records.
+func (t *transpiler) fork() *transpiler {
+	w := *t
+	w.pos = token.Position{}
+	w.path = nil
+	w.rec = nil
+	w.touched = nil
+	w.diags = nil
+	return &w
+}
+
+// claim reserves the definition name of the object obj, found at pos, in
+// mod. It reports false if the definition was already claimed, which also
+// guards against infinite recursion. Claiming a name held by another
+// object, e.g. number and number_ after tsIdent, is recorded as an error.
+func (t *transpiler) claim(mod *Module, name string, obj objectKey, pos token.Position) bool {
+	t.shared.mu.Lock()
+	defer t.shared.mu.Unlock()
+	key := claimKey{mod, name}
+	if c, ok := t.shared.claims[key]; ok {
+		if c.obj != obj && t.shared.err == nil {
+			t.shared.err = fmt.Errorf(
+				"%s and %s are both emitted as %s in module %s; rename one of them with //tgtt:name",
+				c.obj.name, obj.name, name, mod.GoPath,
+			)
+		}
+		return false
+	}
+	t.shared.claims[key] = claim{obj, pos}
+	return true
+}
+
+// objectPos returns the source position of obj.
+func (t *transpiler) objectPos(obj types.Object) token.Position {
+	if pkg := obj.Pkg(); pkg != nil {
+		return t.packages[pkg.Path()].Fset.Position(obj.Pos())
+	}
+	return token.Position{}
+}
+
+func (t *transpiler) setDef(mod *Module, name string, def []byte) {
+	t.shared.mu.Lock()
+	defer t.shared.mu.Unlock()
+	mod.Defs.Set(name, bytesToString(def))
+	if t.rec != nil {
+		t.rec.Def = bytesToString(def)
+	}
+}
+
+func (t *transpiler) hasDef(mod *Module, name string) bool {
+	t.shared.mu.Lock()
+	defer t.shared.mu.Unlock()
+	return mod.Defs.Has(name)
+}
+
+// sortModules orders the contents of all modules independently of the
+// scheduling of the workers: definitions by source position, imports by
+// name and diagnostics by position.
+func (t *transpiler) sortModules() {
+	for _, mod := range t.modules {
+		names := slices.Collect(mod.Defs.Keys())
+		slices.SortFunc(names, func(a, b string) int {
+			posA, posB := t.shared.claims[claimKey{mod, a}].pos, t.shared.claims[claimKey{mod, b}].pos
+			if c := comparePositions(posA, posB); c != 0 {
+				return c
+			}
+			return strings.Compare(a, b)
+		})
+		defs := orderedmap.NewOrderedMap[string, string]()
+		for _, name := range names {
+			def, _ := mod.Defs.Get(name)
+			defs.Set(name, def)
+		}
+		mod.Defs = defs
+
+		imports := orderedmap.NewOrderedMap[string, *Module]()
+		for _, name := range slices.Sorted(mod.Imports.Keys()) {
+			imported, _ := mod.Imports.Get(name)
+			imports.Set(name, imported)
+		}
+		mod.Imports = imports
+
+		typeImports := orderedmap.NewOrderedMap[string, *orderedset.OrderedSet[string]]()
+		for _, from := range slices.Sorted(mod.TypeImports.Keys()) {
+			x, _ := mod.TypeImports.Get(from)
+			var names []string
+			for _, name := range x.All() {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			sorted := orderedset.New[string](len(names))
+			for _, name := range names {
+				sorted.Insert(name)
+			}
+			typeImports.Set(from, sorted)
+		}
+		mod.TypeImports = typeImports
+
+		sortDiagnostics(mod.Diagnostics)
+	}
+}
+
+func comparePositions(a, b token.Position) int {
+	if c := strings.Compare(a.Filename, b.Filename); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.Line, b.Line); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Column, b.Column)
+}
@@ -3,20 +3,30 @@
 
 package tgtt
 
-import "github.com/fatih/structtag"
+import (
+	"go/token"
+	"reflect"
+	"strings"
+
+	"github.com/fatih/structtag"
+)
 
 type structInfo[T any] struct {
 	Fields   []fieldInfo[T]
-	Embedded []T
+	Embedded []fieldInfo[T]
 }
 
 type fieldInfo[T any] struct {
-	Name     string
-	Optional bool
-	Type     T
+	Pos          token.Pos
+	Name         string
+	Optional     bool
+	Readonly     bool
+	Type         T
+	TypeOverride string // set by the type option of the tgtt tag
 }
 
 func parseFieldTag[T any](s string) func(f *fieldInfo[T]) (skip bool) {
+	parseTgtt := parseTgttTag[T](s)
 	afterParse := func(f *fieldInfo[T]) bool {
 		switch f.Name {
 		case "-":
@@ -24,7 +34,7 @@ func parseFieldTag[T any](s string) func(f *fieldInfo[T]) (skip bool) {
 		case "'-'":
 			f.Name = "-"
 		}
-		return false
+		return parseTgtt(f)
 	}
 	tags, err := structtag.Parse(s)
 	if err != nil {
@@ -48,3 +58,74 @@ func parseFieldTag[T any](s string) func(f *fieldInfo[T]) (skip bool) {
 		return afterParse(f)
 	}
 }
+
+// parseTgttTag parses the tgtt tag, whose options take precedence over the
+// json tag:
+//   - "-" hides the field
+//   - "optional" marks the field as optional
+//   - "readonly" marks the field as readonly
+//   - "type=..." replaces the transpiled type of the field
+func parseTgttTag[T any](s string) func(f *fieldInfo[T]) (skip bool) {
+	value, ok := reflect.StructTag(s).Lookup("tgtt")
+	if !ok {
+		return func(*fieldInfo[T]) bool { return false }
+	}
+	var skip, optional, readonly bool
+	var typeOverride string
+	for _, opt := range splitTagOptions(value) {
+		switch opt = strings.TrimSpace(opt); {
+		case opt == "-":
+			skip = true
+		case opt == "optional":
+			optional = true
+		case opt == "readonly":
+			readonly = true
+		case strings.HasPrefix(opt, "type="):
+			typeOverride = strings.TrimSpace(strings.TrimPrefix(opt, "type="))
+		}
+	}
+	return func(f *fieldInfo[T]) bool {
+		if skip {
+			return true
+		}
+		if optional && f.Name != "" { // embedded field cannot be optional
+			f.Optional = true
+		}
+		if readonly {
+			f.Readonly = true
+		}
+		if typeOverride != "" {
+			f.TypeOverride = typeOverride
+		}
+		return false
+	}
+}
+
+// splitTagOptions splits s on commas that are not nested inside brackets
+// or quotes, so that options like "type=Record<string, number>" are kept
+// intact.
+func splitTagOptions(s string) []string {
+	var opts []string
+	var depth int
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case r == '>' && i > 0 && s[i-1] == '=': // arrow function
+		case r == '<' || r == '(' || r == '[' || r == '{':
+			depth++
+		case r == '>' || r == ')' || r == ']' || r == '}':
+			depth = max(depth-1, 0)
+		case r == ',' && depth == 0:
+			opts = append(opts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(opts, s[start:])
+}
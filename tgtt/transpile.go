@@ -6,78 +6,332 @@
 import (
 	"bytes"
 	"cmp"
+	"context"
+	"fmt"
 	"go/constant"
 	"go/types"
 	"hash/maphash"
+	"maps"
 	"math/big"
+	"path"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/antoniszymanski/collections-go/set"
-	"github.com/antoniszymanski/loadpackage-go"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/tools/go/packages"
 )
 
 func Transpile(opts TranspileOptions) (Package, error) {
+	return TranspileContext(context.Background(), opts)
+}
+
+// TranspileContext is like Transpile, but stops loading and transpiling
+// packages once ctx is done.
+func TranspileContext(ctx context.Context, opts TranspileOptions) (Package, error) {
+	typeMappings, err := mergeTypeMappings(opts.TypeMappings, opts.ImportedTypeMappings)
+	if err != nil {
+		return nil, err
+	}
+	presetMappings, err := presetTypeMappings(opts.Presets)
+	if err != nil {
+		return nil, err
+	}
 	t := &transpiler{
-		typeMappings:      opts.TypeMappings,
+		typeMappings:      newTypeMapper(typeMappings, presetMappings),
 		includeUnexported: opts.IncludeUnexported,
 		fallbackType:      opts.FallbackType,
+		layout:            opts.Layout,
+		declaration:       opts.Declaration,
+		progress:          opts.Progress,
+		strict:            opts.Strict,
+		shared: &shared{
+			claims:  make(map[claimKey]claim),
+			records: make(map[claimKey]*defRecord),
+		},
 	}
 	cfg := &packages.Config{
+		Context: ctx,
 		Mode: packages.NeedName |
+			packages.NeedFiles |
 			packages.NeedImports |
+			packages.NeedModule |
 			packages.NeedDeps |
+			packages.NeedSyntax |
 			packages.NeedTypesInfo,
 	}
-	var err error
-	t.primaryPkg, err = loadpackage.Load("pattern="+opts.PrimaryPackage.Path, cfg)
+	if err = checkPackageOptions(opts); err != nil {
+		return nil, err
+	}
+	loadCfg := cfg
+	if opts.CacheDir != "" {
+		if t.cache, err = newPkgCache(opts.CacheDir, opts); err != nil {
+			return nil, err
+		}
+		// packages are listed, and only type-checked if their entries miss
+		loadCfg = &packages.Config{Context: ctx, Mode: listMode}
+	}
+	matches, err := loadPackages(loadCfg, append([]PackageOptions{opts.PrimaryPackage}, opts.SecondaryPackages...))
 	if err != nil {
 		return nil, err
 	}
-	for _, pkgOpts := range opts.SecondaryPackages {
-		pkg, err := loadpackage.Load("pattern="+pkgOpts.Path, cfg)
-		if err != nil {
+	t.primaryPkg = matches[0][0]
+	opts.SecondaryPackages = t.expandPackages(opts.SecondaryPackages, matches[1:])
+	var cached map[string]cachedPackage
+	if t.cache != nil {
+		names := []set.Set[string]{opts.PrimaryPackage.Names}
+		for _, o := range opts.SecondaryPackages {
+			names = append(names, o.Names)
+		}
+		if cached, err = t.lookupCache(cfg, names); err != nil {
 			return nil, err
 		}
-		t.secondaryPkgs = append(t.secondaryPkgs, pkg)
 	}
-	t.init()
+	for _, pkg := range append([]*packages.Package{t.primaryPkg}, t.secondaryPkgs...) {
+		if pkg.TypesInfo != nil {
+			t.report(Event{Kind: EventPackageLoaded, PkgPath: pkg.PkgPath})
+		}
+	}
+	if err = t.initPackageOptions(opts); err != nil {
+		return nil, err
+	}
+	if err = t.init(); err != nil {
+		return nil, err
+	}
 
-	transpile := func(pkg *packages.Package, names set.Set[string]) {
-		for _, obj := range sortedDefs(pkg) {
-			if !names.Empty() && names.Contains(obj.Name()) {
-				t.transpileObject(obj, t.modules[pkg.Name])
-			} else if names.Empty() && (obj.Exported() || t.includeUnexported) {
-				t.transpileObject(obj, t.modules[pkg.Name])
+	// packages are transpiled concurrently, each by its own worker
+	type job struct {
+		pkg   *packages.Package
+		mod   *Module
+		names set.Set[string]
+		key   string // Cache key, if any
+		w     *transpiler
+	}
+	jobs := []*job{{pkg: t.primaryPkg, mod: t.modules[t.primaryPkg.Name], names: opts.PrimaryPackage.Names}}
+	for i, pkg := range t.secondaryPkgs {
+		jobs = append(jobs, &job{pkg: pkg, mod: t.modules[pkg.Name], names: opts.SecondaryPackages[i].Names})
+	}
+	if t.cache != nil {
+		// cached packages are replayed before the workers start, so that
+		// the workers skip their definitions
+		var missed []*job
+		for _, j := range jobs {
+			c := cached[j.pkg.PkgPath]
+			j.key = c.key
+			if c.entry == nil {
+				missed = append(missed, j)
+				continue
 			}
+			if err = t.replay(c.entry, j.mod); err != nil {
+				return nil, err
+			}
+			t.report(Event{Kind: EventPackageCached, PkgPath: j.pkg.PkgPath, Module: j.pkg.Name})
 		}
+		jobs = missed
+	}
+	var g errgroup.Group
+	for _, j := range jobs {
+		w := t.fork()
+		j.w = w
+		g.Go(func() error {
+			idx := w.indexOf(j.pkg)
+			for _, obj := range idx.defs {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if w.isSelected(obj, j.names) {
+					w.transpileObject(obj, j.mod)
+				}
+			}
+			for _, obj := range idx.unsupported {
+				if w.isSelected(obj, j.names) {
+					w.warnUnsupported(obj, j.mod)
+				}
+			}
+			return nil
+		})
 	}
-	transpile(t.primaryPkg, opts.PrimaryPackage.Names)
-	for i, pkg := range t.secondaryPkgs {
-		transpile(pkg, opts.SecondaryPackages[i].Names)
+	if err = g.Wait(); err != nil {
+		return nil, err
+	}
+	if err = t.shared.err; err != nil {
+		return nil, err
+	}
+	t.sortModules()
+	if err = t.addBarrel(opts.Barrel); err != nil {
+		return nil, err
+	}
+	if err = t.checkPaths(); err != nil {
+		return nil, err
+	}
+	if opts.Strict {
+		if err = t.checkStrict(opts.StrictAllow); err != nil {
+			return nil, err
+		}
+	}
+	if t.cache != nil {
+		// the cache is best-effort
+		for _, j := range jobs {
+			if j.key != "" {
+				_ = t.cache.store(j.key, t.cacheEntry(j.pkg.Name, j.w.touched, j.w.diags))
+			}
+		}
+		_ = t.cache.trim()
 	}
 	return t.modules, nil
 }
 
+func checkPackageOptions(opts TranspileOptions) error {
+	if isWildcard(opts.PrimaryPackage.Path) {
+		return fmt.Errorf("%s: primary package path cannot contain wildcards", opts.PrimaryPackage.Path)
+	}
+	for _, o := range opts.SecondaryPackages {
+		if isWildcard(o.Path) && (o.ModuleName != "" || o.Output != "") {
+			return fmt.Errorf("%s: module name and output cannot be set for a pattern with wildcards", o.Path)
+		}
+	}
+	return nil
+}
+
+// expandPackages sets t.secondaryPkgs to the packages matched by opts and
+// returns the options of each of them. Every package except the primary
+// one is added once, at its first match. The options of the first entry
+// without wildcards that matches a package take precedence over those of
+// patterns, wherever the entry is.
+func (t *transpiler) expandPackages(opts []PackageOptions, matches [][]*packages.Package) []PackageOptions {
+	explicit := make(map[string]PackageOptions) // Keyed by package path
+	for i, o := range opts {
+		if isWildcard(o.Path) {
+			continue
+		}
+		path := matches[i][0].PkgPath
+		if _, ok := explicit[path]; !ok {
+			explicit[path] = o
+		}
+	}
+	var expanded []PackageOptions
+	seen := set.From(t.primaryPkg.PkgPath)
+	for i, o := range opts {
+		for _, pkg := range matches[i] {
+			if seen.Contains(pkg.PkgPath) {
+				continue
+			}
+			seen.Insert(pkg.PkgPath)
+			pkgOpts := o
+			if x, ok := explicit[pkg.PkgPath]; ok {
+				pkgOpts = x
+			}
+			pkgOpts.Path = pkg.PkgPath
+			pkgOpts.Exclude = nil
+			expanded = append(expanded, pkgOpts)
+			t.secondaryPkgs = append(t.secondaryPkgs, pkg)
+		}
+	}
+	return expanded
+}
+
+func (t *transpiler) initPackageOptions(opts TranspileOptions) error {
+	if name := opts.PrimaryPackage.ModuleName; name != "" && name != "index" {
+		return fmt.Errorf("module name of the primary package must be %q, got %q", "index", name)
+	}
+	t.moduleNames = maps.Clone(opts.ModuleNames)
+	if t.moduleNames == nil {
+		t.moduleNames = make(map[string]string)
+	}
+	delete(t.moduleNames, t.primaryPkg.PkgPath)
+	t.outputs = make(map[string]string)
+	pkgs := append([]*packages.Package{t.primaryPkg}, t.secondaryPkgs...)
+	pkgOpts := append([]PackageOptions{opts.PrimaryPackage}, opts.SecondaryPackages...)
+	for i, pkg := range pkgs {
+		if name := pkgOpts[i].ModuleName; name != "" && i > 0 {
+			t.moduleNames[pkg.PkgPath] = name
+		}
+		if output := pkgOpts[i].Output; output != "" {
+			if !filepath.IsLocal(filepath.FromSlash(output)) {
+				return fmt.Errorf("output path %q of %s is not a local path", output, pkg.PkgPath)
+			}
+			t.outputs[pkg.PkgPath] = path.Clean(output)
+		}
+	}
+	return nil
+}
+
 type TranspileOptions struct {
 	PrimaryPackage    PackageOptions
 	SecondaryPackages []PackageOptions
 	TypeMappings      map[string]string
+	Presets           []string // Names of presets merged under TypeMappings
 	IncludeUnexported bool
 	FallbackType      string
+	Declaration       bool // Emit declarations suitable for a .d.ts file
+	Layout            Layout
+	Barrel            Barrel
+	ModuleNames       map[string]string // Module names keyed by package path
+	// ImportedTypeMappings are like TypeMappings, but may import the
+	// TypeScript type from a module. A key cannot be in both.
+	ImportedTypeMappings map[string]TypeMapping
+	// Strict makes Transpile fail with a *StrictError when a type is
+	// emitted as the fallback type, as any (including empty interfaces
+	// outside of constraints) or through a lossy mapping.
+	Strict bool
+	// StrictAllow lists the paths exempt from Strict, e.g.
+	// index.Order.Items[].Meta. '*' and '?' act as wildcards.
+	StrictAllow []string
+	// Progress, if set, is called synchronously as Transpile makes progress.
+	Progress func(Event) `json:"-"`
+	// CacheDir, if set, is the directory of an on-disk cache of the
+	// definitions of each package, keyed by the options, the sources of
+	// the package and the keys of its dependencies. Entries unused for a
+	// week are removed. See DefaultCacheDir.
+	CacheDir string `json:"-"`
 }
 
+// Layout determines the output paths of modules.
+type Layout string
+
+const (
+	// LayoutFlat places every module at the top level, named after the
+	// module.
+	LayoutFlat Layout = "flat"
+	// LayoutTree places modules at paths derived from their Go import
+	// paths: relative to the main module root for packages of the main
+	// module, and the full import path for other packages. The primary
+	// package is always placed at index.
+	LayoutTree Layout = "tree"
+)
+
+// PackageOptions selects packages to transpile. The Path of a secondary
+// package may be a Go package pattern such as ./api/..., which expands into
+// one module per matched package, minus those matched by Exclude.
 type PackageOptions struct {
-	Path  string          `json:"path" jsonschema:"required,minLength=1"`
-	Names set.Set[string] `json:"names"`
+	Path       string          `json:"path" jsonschema:"required,minLength=1"`
+	Exclude    []string        `json:"exclude,omitzero"` // Package patterns, e.g. ./api/internal/...
+	Names      set.Set[string] `json:"names"`
+	ModuleName string          `json:"module_name,omitzero"`
+	Output     string          `json:"output,omitzero"` // Slash-separated output path without a file extension
+}
+
+func (t *transpiler) isSelected(obj types.Object, names set.Set[string]) bool {
+	switch d := t.directivesOf(obj); {
+	case d.Ignore:
+		return false
+	case d.Export:
+		return true
+	case !names.Empty():
+		return names.Contains(obj.Name())
+	default:
+		return obj.Exported() || t.includeUnexported
+	}
 }
 
-func sortedDefs(pkg *packages.Package) []types.Object {
+// sortedDefs returns the objects defined in pkg for which keep reports
+// true, sorted by position.
+func sortedDefs(pkg *packages.Package, keep func(types.Object) bool) []types.Object {
 	var defs []types.Object
 	for _, obj := range pkg.TypesInfo.Defs {
-		if isTranspilable(obj) {
+		if obj != nil && keep(obj) {
 			defs = append(defs, obj)
 		}
 	}
@@ -95,6 +349,17 @@ func(a, b types.Object) int {
 	return defs
 }
 
+// isUnsupported reports whether obj is a package-level constant or type
+// that cannot be transpiled.
+func isUnsupported(obj types.Object) bool {
+	switch obj.(type) {
+	case *types.Const, *types.TypeName:
+		return obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope() && !isTranspilable(obj)
+	default:
+		return false
+	}
+}
+
 func isTranspilable(obj types.Object) bool {
 	if obj == nil {
 		return false
@@ -118,9 +383,20 @@ type transpilableType interface {
 }
 
 func (t *transpiler) transpileObject(obj types.Object, mod *Module) {
-	if mod.Defs.Has(obj.Name()) {
+	name := t.tsName(obj)
+	module := t.packages[obj.Pkg().Path()].Name
+	t.recordRef(module, name)
+	if !t.claim(mod, name, keyOf(obj), t.objectPos(obj)) {
 		return
 	}
+	if t.cache != nil {
+		prev := t.rec
+		t.rec = t.record(mod, module, name, obj)
+		defer func() { t.rec = prev }()
+	}
+	defer t.setPos(obj.Pkg(), obj.Pos())()
+	defer t.setPath(obj)()
+	defer t.setInConstraint(false)()
 	// https://github.com/golang/example/tree/master/gotypes#objects
 	switch obj := obj.(type) {
 	case *types.Const:
@@ -128,13 +404,35 @@ func (t *transpiler) transpileObject(obj types.Object, mod *Module) {
 	case *types.TypeName:
 		t.transpileTypeDef(obj, mod)
 	}
+	if t.hasDef(mod, name) {
+		t.report(Event{
+			Kind:    EventDefinitionEmitted,
+			PkgPath: mod.GoPath,
+			Module:  module,
+			Name:    name,
+		})
+	}
 }
 
 func (t *transpiler) transpileConst(obj *types.Const, mod *Module) {
-	mod.Defs.Set(obj.Name(), "") // prevent infinite recursion
-	def := append([]byte(nil), "export const "...)
-	def = append(def, obj.Name()...)
+	name := t.tsName(obj)
+	var def []byte
 	var ok bool
+	if t.declaration {
+		def = append(def, "export declare const "...)
+		def = append(def, name...)
+		def = append(def, ": "...)
+		_, isNamed := obj.Type().(*types.Named)
+		def, ok = transpileConstVal(def, obj.Val(), !isNamed)
+		if !ok {
+			t.warnUnsupported(obj, mod)
+			return
+		}
+		t.setDef(mod, name, def)
+		return
+	}
+	def = append(def, "export const "...)
+	def = append(def, name...)
 	switch typ := obj.Type().(type) {
 	case *types.Named:
 		def = append(def, ": "...)
@@ -146,10 +444,22 @@ func (t *transpiler) transpileConst(obj *types.Const, mod *Module) {
 		def, ok = transpileConstVal(def, obj.Val(), true)
 	}
 	if !ok {
-		mod.Defs.Delete(obj.Name())
+		t.warnUnsupported(obj, mod)
 		return
 	}
-	mod.Defs.Set(obj.Name(), bytesToString(def))
+	t.setDef(mod, name, def)
+}
+
+// warnUnsupported reports that obj was dropped.
+func (t *transpiler) warnUnsupported(obj types.Object, mod *Module) {
+	defer t.setPos(obj.Pkg(), obj.Pos())()
+	defer t.setPath(obj)()
+	switch obj := obj.(type) {
+	case *types.Const:
+		t.warn(mod, CodeUnsupportedConstant, "constant %s with value %s cannot be represented", obj.Name(), obj.Val())
+	default:
+		t.warn(mod, CodeSkippedType, "type %s of kind %T is not supported", obj.Name(), obj.Type())
+	}
 }
 
 func transpileConstVal(dst []byte, x constant.Value, allowBigint bool) ([]byte, bool) {
@@ -186,20 +496,51 @@ func transpileConstVal(dst []byte, x constant.Value, allowBigint bool) ([]byte,
 func (t *transpiler) transpileTypeDef(obj *types.TypeName, mod *Module) {
 	typ, ok := obj.Type().(transpilableType)
 	if !ok {
+		t.warnUnsupported(obj, mod)
 		return
 	}
-	mod.Defs.Set(obj.Name(), "") // prevent infinite recursion
+	name := t.tsName(obj)
 	def := append([]byte(nil), "export type "...)
-	def = append(def, typ.Obj().Name()...)
+	def = append(def, name...)
 	def = t.transpileTypeParams(def, typ.TypeParams(), mod)
 	def = append(def, " = "...)
+	d := t.directivesOf(obj)
 	qualifiedName := t.qualifiedName(typ.Obj())
-	if x, ok := t.typeMappings[qualifiedName]; ok {
-		def = append(def, x...)
+	if d.Type != "" {
+		def = append(def, d.Type...)
+	} else if x, ok := t.typeMappings.lookup(qualifiedName); ok {
+		def = t.transpileTypeMapping(def, x, mod)
 	} else {
-		def = t.transpileType(def, typ.Underlying(), mod)
+		var ok bool
+		if d.Enum {
+			if def, ok = t.transpileEnum(def, obj); !ok {
+				t.warn(mod, CodeEmptyEnum, "enum %s has no representable constants", obj.Name())
+			}
+		}
+		if !ok {
+			def = t.transpileType(def, typ.Underlying(), mod)
+		}
+	}
+	t.setDef(mod, name, def)
+}
+
+// transpileEnum transpiles obj as the union of the values of its constants.
+func (t *transpiler) transpileEnum(dst []byte, obj *types.TypeName) ([]byte, bool) {
+	n := len(dst)
+	for _, c := range t.constsOf(obj) {
+		if !t.isSelected(c, set.Set[string]{}) {
+			continue
+		}
+		val, ok := transpileConstVal(dst, c.Val(), false)
+		if !ok {
+			continue
+		}
+		dst = append(val, " | "...)
 	}
-	mod.Defs.Set(obj.Name(), bytesToString(def))
+	if len(dst) == n {
+		return dst, false
+	}
+	return bytes.TrimSuffix(dst, []byte(" | ")), true
 }
 
 func (t *transpiler) transpileType(dst []byte, typ types.Type, mod *Module) []byte {
@@ -228,13 +569,26 @@ func (t *transpiler) transpileType(dst []byte, typ types.Type, mod *Module) []by
 	case *types.TypeParam:
 		return t.transpileTypeParam(dst, typ, mod)
 	default:
+		t.warnFallback(mod, typ)
 		return append(dst, t.fallbackType...)
 	}
 }
 
-func (t *transpiler) transpileBasic(dst []byte, typ *types.Basic, _ *Module) []byte {
-	if x, ok := t.typeMappings["_."+typ.Name()]; ok {
-		return append(dst, x...)
+// warnAny reports an empty interface outside of a constraint. It is only
+// reported in strict mode, where it counts as lossy.
+func (t *transpiler) warnAny(mod *Module) {
+	if t.strict && !t.inConstraint {
+		t.warn(mod, CodeAnyType, "empty interface emitted as any")
+	}
+}
+
+func (t *transpiler) warnFallback(mod *Module, typ types.Type) {
+	t.warn(mod, CodeFallbackType, "unsupported type %s emitted as %s", types.TypeString(typ, nil), t.fallbackType)
+}
+
+func (t *transpiler) transpileBasic(dst []byte, typ *types.Basic, mod *Module) []byte {
+	if x, ok := t.typeMappings.lookup("_." + typ.Name()); ok {
+		return t.transpileTypeMapping(dst, x, mod)
 	}
 	switch typ.Kind() {
 	case types.Bool:
@@ -268,6 +622,7 @@ func (t *transpiler) transpileBasic(dst []byte, typ *types.Basic, _ *Module) []b
 	case types.String:
 		return append(dst, "string"...)
 	default:
+		t.warnFallback(mod, typ)
 		return append(dst, t.fallbackType...)
 	}
 }
@@ -281,12 +636,14 @@ func (t *transpiler) transpilePointer(dst []byte, typ *types.Pointer, mod *Modul
 }
 
 func (t *transpiler) transpileArray(dst []byte, typ *types.Array, mod *Module) []byte {
+	defer t.pushPath("[]")()
 	dst = t.transpileType(dst, typ.Elem(), mod)
 	dst = append(dst, "[]"...)
 	return dst
 }
 
 func (t *transpiler) transpileSlice(dst []byte, typ *types.Slice, mod *Module) []byte {
+	defer t.pushPath("[]")()
 	dst = t.transpileType(dst, typ.Elem(), mod)
 	dst = append(dst, "[]"...)
 	return dst
@@ -294,34 +651,55 @@ func (t *transpiler) transpileSlice(dst []byte, typ *types.Slice, mod *Module) [
 
 func (t *transpiler) transpileMap(dst []byte, typ *types.Map, mod *Module) []byte {
 	dst = append(dst, "{ [key in string]: "...)
+	pop := t.pushPath("{}")
 	dst = t.transpileType(dst, typ.Elem(), mod)
+	pop()
 	dst = append(dst, " }"...)
 	return dst
 }
 
 func (t *transpiler) transpileStruct(dst []byte, typ *types.Struct, mod *Module) []byte {
 	s := parseStruct(typ)
+	var pkg *types.Package
+	if typ.NumFields() > 0 {
+		pkg = typ.Field(0).Pkg()
+	}
 	dst = append(dst, '{')
 	if len(s.Fields) > 0 {
 		dst = append(dst, ' ')
 	}
 	for i, field := range s.Fields {
+		restore := t.setPos(pkg, field.Pos)
+		pop := t.pushPath(".", field.Name)
+		if field.Readonly {
+			dst = append(dst, "readonly "...)
+		}
 		dst = strconv.AppendQuote(dst, field.Name)
 		if field.Optional {
 			dst = append(dst, '?')
 		}
 		dst = append(dst, ": "...)
-		dst = t.transpileType(dst, field.Type, mod)
+		if field.TypeOverride != "" {
+			dst = append(dst, field.TypeOverride...)
+		} else {
+			dst = t.transpileType(dst, field.Type, mod)
+		}
 		if i < len(s.Fields)-1 {
 			dst = append(dst, ';')
 		}
 		dst = append(dst, ' ')
+		pop()
+		restore()
 	}
 	dst = append(dst, '}')
 	for _, embedded := range s.Embedded {
 		dst = append(dst, " & "...)
+		if embedded.TypeOverride != "" {
+			dst = append(dst, embedded.TypeOverride...)
+			continue
+		}
 		i := len(dst)
-		dst = t.transpileType(dst, embedded, mod)
+		dst = t.transpileType(dst, embedded.Type, mod)
 		var found bool
 		dst, found = bytes.CutSuffix(dst, []byte(" | null"))
 		if found {
@@ -339,7 +717,7 @@ func parseStruct(typ *types.Struct) structInfo[types.Type] {
 		if !field.Exported() {
 			continue
 		}
-		f := fieldInfo[types.Type]{Name: field.Name(), Type: field.Type()}
+		f := fieldInfo[types.Type]{Pos: field.Pos(), Name: field.Name(), Type: field.Type()}
 		if field.Embedded() {
 			f.Name = ""
 		}
@@ -347,7 +725,7 @@ func parseStruct(typ *types.Struct) structInfo[types.Type] {
 			continue
 		}
 		if f.Name == "" {
-			s.Embedded = append(s.Embedded, f.Type)
+			s.Embedded = append(s.Embedded, f)
 		} else {
 			s.Fields = append(s.Fields, f)
 		}
@@ -393,12 +771,18 @@ func (t *transpiler) transpileInterface(dst []byte, typ *types.Interface, mod *M
 		unions = append(unions, terms)
 	}
 	if len(unions) == 0 {
+		if typ.Empty() {
+			t.warnAny(mod)
+		} else {
+			t.warnFallback(mod, typ)
+		}
 		return append(dst, t.fallbackType...)
 	}
 	for _, y := range unions[1:] {
 		unions[0] = intersect(unions[0], y)
 	}
 	if len(unions[0]) == 0 {
+		t.warnFallback(mod, typ)
 		return append(dst, t.fallbackType...)
 	}
 	s := set.New[uint64](0)
@@ -426,6 +810,7 @@ func (t *transpiler) transpileInterface(dst []byte, typ *types.Interface, mod *M
 
 func (t *transpiler) transpileUnion(dst []byte, typ *types.Union, mod *Module) []byte {
 	if typ.Len() == 0 {
+		t.warnFallback(mod, typ)
 		return append(dst, t.fallbackType...)
 	}
 	s := set.New[uint64](0)
@@ -452,5 +837,5 @@ func (t *transpiler) transpileUnion(dst []byte, typ *types.Union, mod *Module) [
 }
 
 func (t *transpiler) transpileTypeParam(dst []byte, typ *types.TypeParam, _ *Module) []byte {
-	return append(dst, typ.Obj().Name()...)
+	return append(dst, tsIdent(typ.Obj().Name())...)
 }
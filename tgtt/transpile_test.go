@@ -0,0 +1,230 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeSyntheticModule writes a module with a package of n struct types,
+// each with a named string type and two constants of it, and a subpackage
+// referencing them. It changes the working directory to the module.
+func writeSyntheticModule(tb testing.TB, n int) {
+	tb.Helper()
+	var pkg bytes.Buffer
+	pkg.WriteString("package synthetic\n")
+	for i := range n {
+		fmt.Fprintf(&pkg, `
+type Kind%[1]d string
+
+const (
+	Kind%[1]dA Kind%[1]d = "a"
+	Kind%[1]dB Kind%[1]d = "b"
+)
+
+type T%[1]d struct {
+	ID   int       `+"`json:\"id\"`"+`
+	Kind Kind%[1]d  `+"`json:\"kind\"`"+`
+	Next *T%[2]d    `+"`json:\"next\"`"+`
+	Tags []string  `+"`json:\"tags\"`"+`
+}
+`, i, (i+1)%n)
+	}
+	var sub bytes.Buffer
+	sub.WriteString("package sub\n\nimport \"example.com/synthetic\"\n")
+	for i := 0; i < n; i += 10 {
+		fmt.Fprintf(&sub, "\ntype Ref%[1]d struct{ T synthetic.T%[1]d }\n", i)
+	}
+	writeModule(tb, map[string]string{
+		"go.mod":       "module example.com/synthetic\n\ngo 1.25\n",
+		"synthetic.go": pkg.String(),
+		"sub/sub.go":   sub.String(),
+	})
+}
+
+// writeModule writes files, keyed by slash-separated path, to a temporary
+// directory and changes the working directory to it.
+func writeModule(tb testing.TB, files map[string]string) {
+	tb.Helper()
+	dir := tb.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			tb.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	tb.Chdir(dir)
+}
+
+var syntheticOptions = TranspileOptions{
+	PrimaryPackage:    PackageOptions{Path: "example.com/synthetic"},
+	SecondaryPackages: []PackageOptions{{Path: "./..."}},
+}
+
+func renderAll(p Package) []byte {
+	var b []byte
+	for _, name := range slices.Sorted(maps.Keys(p)) {
+		b = append(b, name...)
+		b = append(b, '\n')
+		b = append(b, p[name].Render()...)
+		b = append(b, '\n')
+	}
+	return b
+}
+
+func TestTranspileDeterministic(t *testing.T) {
+	writeSyntheticModule(t, 200)
+	var want []byte
+	for i := range 5 {
+		p, err := Transpile(syntheticOptions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Index().Defs.Len(); got != 200*4 {
+			t.Fatalf("got %d definitions in the index module, want %d", got, 200*4)
+		}
+		got := renderAll(p)
+		if i == 0 {
+			want = got
+		} else if !bytes.Equal(got, want) {
+			t.Fatalf("run %d rendered different output", i)
+		}
+	}
+}
+
+func TestStrictAnyReachedThroughConstraint(t *testing.T) {
+	writeModule(t, map[string]string{
+		"go.mod": "module example.com/constraint\n\ngo 1.25\n",
+		"constraint.go": `package constraint
+
+type G[T C] struct{ V T }
+
+type C interface{ S | int }
+
+type S struct{ X any }
+`,
+	})
+	_, err := Transpile(TranspileOptions{
+		PrimaryPackage: PackageOptions{Path: "example.com/constraint"},
+		Strict:         true,
+	})
+	var strictErr *StrictError
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("got error %v, want a *StrictError", err)
+	}
+	if len(strictErr.Diagnostics) != 1 || strictErr.Diagnostics[0].Path != "index.S.X" {
+		t.Fatalf("got diagnostics %v, want one for index.S.X", strictErr.Diagnostics)
+	}
+}
+
+func TestExpandPackagesOnce(t *testing.T) {
+	writeSyntheticModule(t, 10)
+	for _, secondary := range [][]PackageOptions{
+		{{Path: "./..."}, {Path: "./sub", ModuleName: "renamed"}},
+		{{Path: "./sub", ModuleName: "renamed"}, {Path: "./..."}},
+	} {
+		p, err := Transpile(TranspileOptions{
+			PrimaryPackage:    PackageOptions{Path: "example.com/synthetic"},
+			SecondaryPackages: secondary,
+			Barrel:            BarrelNamespace,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := slices.Sorted(maps.Keys(p)); !slices.Equal(got, []string{"index", "renamed"}) {
+			t.Fatalf("got modules %v, want [index renamed]", got)
+		}
+	}
+}
+
+func BenchmarkTranspile(b *testing.B) {
+	for _, n := range []int{1000, 4000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			writeSyntheticModule(b, n)
+			for b.Loop() {
+				if _, err := Transpile(syntheticOptions); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprint(n, "/cached"), func(b *testing.B) {
+			writeSyntheticModule(b, n)
+			opts := syntheticOptions
+			opts.CacheDir = b.TempDir()
+			if _, err := Transpile(opts); err != nil {
+				b.Fatal(err)
+			}
+			for b.Loop() {
+				if _, err := Transpile(opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestTranspileCache(t *testing.T) {
+	writeSyntheticModule(t, 50)
+	p, err := Transpile(syntheticOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := renderAll(p)
+
+	opts := syntheticOptions
+	opts.CacheDir = t.TempDir()
+	var cached int
+	var loaded []string // Type-checked packages
+	opts.Progress = func(e Event) {
+		switch e.Kind {
+		case EventPackageCached:
+			cached++
+		case EventPackageLoaded:
+			loaded = append(loaded, e.PkgPath)
+		}
+	}
+	check := func(run string, wantCached int, wantLoaded ...string) {
+		t.Helper()
+		cached, loaded = 0, nil
+		p, err := Transpile(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(renderAll(p), want) {
+			t.Fatalf("%s: rendered different output than without the cache", run)
+		}
+		if cached != wantCached {
+			t.Fatalf("%s: got %d cached packages, want %d", run, cached, wantCached)
+		}
+		slices.Sort(loaded)
+		if !slices.Equal(loaded, wantLoaded) {
+			t.Fatalf("%s: type-checked %v, want %v", run, loaded, wantLoaded)
+		}
+	}
+	check("cold run", 0, "example.com/synthetic", "example.com/synthetic/sub")
+	check("warm run", 2)
+
+	// only the edited package misses
+	f, err := os.OpenFile(filepath.Join("sub", "sub.go"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteString("\n// edited\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	check("run after an edit", 1, "example.com/synthetic/sub")
+}
@@ -4,13 +4,12 @@
 package tgtt
 
 import (
-	"cmp"
+	"fmt"
+	"go/token"
 	"slices"
-	"strconv"
 	"strings"
 
 	"github.com/antoniszymanski/collections-go/set"
-	"github.com/elliotchance/orderedmap/v3"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -19,17 +18,46 @@ type transpiler struct {
 	secondaryPkgs     []*packages.Package
 	packages          map[string]*packages.Package // Keyed by package path
 	modules           Package
-	typeMappings      map[string]string
+	typeMappings      typeMapper
 	includeUnexported bool
 	fallbackType      string
+	layout            Layout
+	moduleNames       map[string]string // Keyed by package path
+	outputs           map[string]string // Keyed by package path
+	declaration       bool
+	directives        map[objectKey]directives
+	indexes           map[string]*pkgIndex // Keyed by package path
+	pos               token.Position       // Position reported by diagnostics
+	path              []string             // Path reported by diagnostics
+	progress          func(Event)
+	strict            bool
+	inConstraint      bool // Whether a type parameter constraint is being transpiled
+	cache             *pkgCache
+	rec               *defRecord   // Cache record of the definition being transpiled
+	touched           []defRef     // Definitions transpiled by the worker itself
+	diags             []Diagnostic // Diagnostics of the worker outside of definitions
+	shared            *shared
 }
 
 func (t *transpiler) init1() {
 	t.packages = make(map[string]*packages.Package)
-	stack := append([]*packages.Package{t.primaryPkg}, t.secondaryPkgs...)
+	// with the cache, packages that were only listed may also have been
+	// type-checked as dependencies; type-checked packages are visited first
+	// so that they take precedence
+	var stack []*packages.Package
+	for _, typed := range []bool{false, true} {
+		for _, pkg := range append([]*packages.Package{t.primaryPkg}, t.secondaryPkgs...) {
+			if (pkg.TypesInfo != nil) == typed {
+				stack = append(stack, pkg)
+			}
+		}
+	}
 	for len(stack) > 0 {
 		current := stack[len(stack)-1]
 		stack = stack[:len(stack)-1]
+		if _, ok := t.packages[current.PkgPath]; ok {
+			continue
+		}
 		t.packages[current.PkgPath] = current
 		for _, importedPkg := range current.Imports {
 			if _, ok := t.packages[importedPkg.PkgPath]; ok {
@@ -38,9 +66,13 @@ func (t *transpiler) init1() {
 			stack = append(stack, importedPkg)
 		}
 	}
+	t.primaryPkg = t.packages[t.primaryPkg.PkgPath]
+	for i, pkg := range t.secondaryPkgs {
+		t.secondaryPkgs[i] = t.packages[pkg.PkgPath]
+	}
 }
 
-func (t *transpiler) init2() {
+func (t *transpiler) init2() error {
 	pkgs := make([]*packages.Package, 0, len(t.packages)-1)
 	for _, pkg := range t.packages {
 		if t.primaryPkg.PkgPath != pkg.PkgPath {
@@ -48,69 +80,123 @@ func (t *transpiler) init2() {
 		}
 	}
 	slices.SortFunc(pkgs, func(a, b *packages.Package) int {
-		is_a_secondary := slices.ContainsFunc(t.secondaryPkgs, func(elem *packages.Package) bool {
-			return a.PkgPath == elem.PkgPath
-		})
-		is_b_secondary := slices.ContainsFunc(t.secondaryPkgs, func(elem *packages.Package) bool {
-			return b.PkgPath == elem.PkgPath
-		})
-		if is_a_secondary && !is_b_secondary {
-			return -1
-		} else if !is_a_secondary && is_b_secondary {
-			return 1
+		return strings.Compare(a.PkgPath, b.PkgPath)
+	})
+	names := set.From("index")
+	owners := make(map[string]string) // Keyed by explicit module name
+	for _, pkg := range pkgs {
+		name, ok := t.moduleNames[pkg.PkgPath]
+		if !ok {
+			continue
 		}
-		if c := cmp.Compare(len(a.PkgPath), len(b.PkgPath)); c != 0 {
-			return c
+		name = tsIdent(name)
+		if owner, ok := owners[name]; ok {
+			return fmt.Errorf("module name %q is used by both %s and %s", name, owner, pkg.PkgPath)
+		} else if names.Contains(name) {
+			return fmt.Errorf("module name %q of %s is reserved", name, pkg.PkgPath)
 		}
-		return strings.Compare(a.Name, b.Name)
-	})
-	names := set.New[string](0)
+		owners[name] = pkg.PkgPath
+		names.Insert(name)
+		pkg.Name = name
+	}
+	// implicit names may collide; moduleOf reports collisions between the
+	// modules that are actually emitted
 	for _, pkg := range pkgs {
-		if names.Contains(pkg.Name) {
-			name := make([]byte, 0, len(pkg.Name)+1+1)
-			name = append(name, pkg.Name...)
-			name = append(name, '_')
-			for i := uint64(1); ; i++ {
-				name = name[:len(pkg.Name)+1]
-				name = strconv.AppendUint(name, i, 10)
-				if !names.Contains(string(name)) {
-					break
-				}
-			}
-			pkg.Name = bytesToString(name)
+		if _, ok := t.moduleNames[pkg.PkgPath]; !ok {
+			pkg.Name = tsIdent(pkg.Name)
 		}
-		names.Insert(pkg.Name)
 	}
 	t.primaryPkg.Name = "index"
+	return nil
 }
 
-func (t *transpiler) init3() {
+func (t *transpiler) init3() error {
 	t.modules = make(Package, 1+len(t.secondaryPkgs))
-	t.addModule(t.primaryPkg)
-	for _, pkg := range t.secondaryPkgs {
-		t.addModule(pkg)
+	for _, pkg := range append([]*packages.Package{t.primaryPkg}, t.secondaryPkgs...) {
+		if _, _, err := t.moduleOf(pkg); err != nil {
+			return err
+		}
+		t.report(Event{Kind: EventModuleStarted, PkgPath: pkg.PkgPath, Module: pkg.Name})
 	}
+	return nil
 }
 
-func (t *transpiler) addModule(pkg *packages.Package) *Module {
-	module := &Module{
-		GoPath:  pkg.PkgPath,
-		Imports: orderedmap.NewOrderedMap[string, *Module](),
-		Defs:    orderedmap.NewOrderedMap[string, string](),
+// moduleOf returns the module of pkg, adding it if needed, and reports
+// whether it was added. It fails if the module name of pkg is already
+// used by another package.
+func (t *transpiler) moduleOf(pkg *packages.Package) (mod *Module, added bool, err error) {
+	if mod, ok := t.modules[pkg.Name]; ok {
+		if mod.GoPath != pkg.PkgPath {
+			return nil, false, fmt.Errorf(
+				"module name %q is used by both %s and %s; give one of them a different name in module_names",
+				pkg.Name, mod.GoPath, pkg.PkgPath,
+			)
+		}
+		return mod, false, nil
 	}
+	return t.addModule(pkg), true, nil
+}
+
+func (t *transpiler) addModule(pkg *packages.Package) *Module {
+	module := newModule(pkg.PkgPath)
+	module.Path = t.modulePath(pkg)
+	module.GoFiles = pkg.GoFiles
 	t.modules[pkg.Name] = module
 	return module
 }
 
+func (t *transpiler) modulePath(pkg *packages.Package) string {
+	if output, ok := t.outputs[pkg.PkgPath]; ok {
+		return output
+	}
+	if t.layout != LayoutTree || pkg.PkgPath == t.primaryPkg.PkgPath {
+		return pkg.Name
+	}
+	if mainModule := t.primaryPkg.Module; mainModule != nil {
+		if rel, ok := strings.CutPrefix(pkg.PkgPath, mainModule.Path+"/"); ok {
+			return rel
+		} else if pkg.PkgPath == mainModule.Path {
+			return pkg.Name
+		}
+	}
+	return pkg.PkgPath
+}
+
 func (t *transpiler) init4() {
 	if strings.TrimSpace(t.fallbackType) == "" {
 		t.fallbackType = "any"
 	}
 }
 
-func (t *transpiler) init() {
+func (t *transpiler) init5() {
+	t.directives = make(map[objectKey]directives)
+	t.indexes = make(map[string]*pkgIndex)
+	for _, pkg := range t.packages {
+		t.collectDirectives(pkg)
+	}
+}
+
+func (t *transpiler) init() error {
 	t.init1()
-	t.init2()
-	t.init3()
+	if err := t.init2(); err != nil {
+		return err
+	}
+	if err := t.init3(); err != nil {
+		return err
+	}
 	t.init4()
+	t.init5()
+	return nil
+}
+
+// checkPaths reports modules sharing an output path.
+func (t *transpiler) checkPaths() error {
+	owners := make(map[string]string) // Keyed by output path
+	for _, mod := range t.modules {
+		if owner, ok := owners[mod.Path]; ok {
+			return fmt.Errorf("output path %q is used by both %s and %s", mod.Path, owner, mod.GoPath)
+		}
+		owners[mod.Path] = mod.GoPath
+	}
+	return nil
 }
@@ -4,6 +4,12 @@
 package tgtt
 
 import (
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/antoniszymanski/collections-go/orderedset"
 	"github.com/elliotchance/orderedmap/v3"
 	"golang.org/x/sync/errgroup"
 )
@@ -18,19 +24,49 @@ func (p Package) builtin() *Module {
 	if module, ok := p["$builtin"]; ok {
 		return module
 	}
-	module := &Module{
-		GoPath:  "builtin",
-		Imports: orderedmap.NewOrderedMap[string, *Module](),
-		Defs:    orderedmap.NewOrderedMap[string, string](),
-	}
+	module := newModule("builtin")
+	module.Path = "$builtin"
 	module.Defs.Set("comparable", "export type comparable = boolean | number | string | null | comparable[] | { [key: string]: comparable }")
 	p["$builtin"] = module
 	return module
 }
 
 type RenderOptions struct {
-	Limit int
-	Write func(moduleName string, data []byte) error
+	Limit   int
+	Imports ImportOptions
+	Write   func(moduleName string, data []byte) error
+}
+
+// ImportOptions controls how imports between modules are rendered.
+type ImportOptions struct {
+	TypeOnly  bool   // Use `import type` instead of `import`
+	Extension string // Appended to module specifiers, e.g. ".js"
+	BasePath  string // Prefix of module specifiers, e.g. "@api/types"; defaults to "."
+}
+
+func (opts ImportOptions) specifier(from, to *Module) string {
+	if base := strings.TrimSuffix(opts.BasePath, "/"); base != "" && base != "." {
+		return base + "/" + to.Path + opts.Extension
+	}
+	fromDir := strings.Split(path.Dir(from.Path), "/")
+	if fromDir[0] == "." {
+		fromDir = nil
+	}
+	toParts := strings.Split(to.Path, "/")
+	var i int
+	for i < len(fromDir) && i < len(toParts)-1 && fromDir[i] == toParts[i] {
+		i++
+	}
+	var b strings.Builder
+	if i == len(fromDir) {
+		b.WriteString("./")
+	}
+	for range len(fromDir) - i {
+		b.WriteString("../")
+	}
+	b.WriteString(strings.Join(toParts[i:], "/"))
+	b.WriteString(opts.Extension)
+	return b.String()
 }
 
 func (p Package) Render(opts RenderOptions) error {
@@ -40,30 +76,168 @@ func (p Package) Render(opts RenderOptions) error {
 	}
 	for moduleName, mod := range p {
 		g.Go(func() error {
-			return opts.Write(moduleName, mod.Render())
+			return opts.Write(moduleName, mod.RenderImports(opts.Imports))
 		})
 	}
 	return g.Wait()
 }
 
+// RenderBundle renders p into a single file. The definitions of the index
+// module are emitted at the top level, and the definitions of every other
+// module are wrapped in `export namespace name { ... }`, so references of
+// the form name.Type keep working. If another module references the index
+// module, the index definitions are wrapped in `export namespace index`
+// as well and aliased at the top level after the namespace.
+func (p Package) RenderBundle() []byte {
+	index := p.Index()
+	names := make([]string, 0, len(p))
+	var indexReferenced bool
+	for name, mod := range p {
+		if mod == index {
+			continue
+		}
+		if mod.Imports.Has("index") {
+			indexReferenced = true
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	mods := make([]*Module, 0, len(p))
+	mods = append(mods, index)
+	for _, name := range names {
+		mods = append(mods, p[name])
+	}
+	typeImports := orderedmap.NewOrderedMap[string, *orderedset.OrderedSet[string]]()
+	for _, mod := range mods {
+		for from, x := range mod.TypeImports.AllFromFront() {
+			imported, ok := typeImports.Get(from)
+			if !ok {
+				imported = orderedset.New[string](0)
+				typeImports.Set(from, imported)
+			}
+			for _, name := range x.All() {
+				imported.Insert(name)
+			}
+		}
+	}
+
+	var b []byte
+	b = append(b, "/* "...)
+	b = append(b, index.GoPath...)
+	b = append(b, " */"...)
+	b = appendTypeImports(b, typeImports)
+	if indexReferenced {
+		b = append(b, "\n\n"...)
+		b = appendNamespace(b, "index", index)
+		b = append(b, '\n')
+		for name := range index.Defs.Keys() {
+			b = append(b, "\nexport import "...)
+			b = append(b, name...)
+			b = append(b, " = index."...)
+			b = append(b, name...)
+			b = append(b, ';')
+		}
+	} else {
+		for def := range index.Defs.Values() {
+			b = append(b, "\n\n"...)
+			b = append(b, def...)
+		}
+	}
+	for _, name := range names {
+		mod := p[name]
+		b = append(b, "\n\n/* "...)
+		b = append(b, mod.GoPath...)
+		b = append(b, " */\n"...)
+		b = appendNamespace(b, name, mod)
+	}
+	return b
+}
+
+func appendNamespace(b []byte, name string, mod *Module) []byte {
+	b = append(b, "export namespace "...)
+	b = append(b, name...)
+	b = append(b, " {"...)
+	first := true
+	for def := range mod.Defs.Values() {
+		if !first {
+			b = append(b, '\n')
+		}
+		first = false
+		// namespaces are already ambient in a declaration file
+		def = strings.Replace(def, "export declare ", "export ", 1)
+		b = append(b, "\n  "...)
+		b = append(b, strings.ReplaceAll(def, "\n", "\n  ")...)
+	}
+	return append(b, "\n}"...)
+}
+
 type Module struct {
-	GoPath  string
-	Imports *orderedmap.OrderedMap[string, *Module] // Keyed by module name
-	Defs    *orderedmap.OrderedMap[string, string]
+	GoPath      string
+	Path        string // Slash-separated output path without a file extension
+	GoFiles     []string
+	Imports     *orderedmap.OrderedMap[string, *Module]                        // Keyed by module name
+	TypeImports *orderedmap.OrderedMap[string, *orderedset.OrderedSet[string]] // Keyed by module specifier
+	Defs        *orderedmap.OrderedMap[string, string]
+	Reexports   []Reexport // Ignored by Package.RenderBundle
+	Diagnostics []Diagnostic
+}
+
+func newModule(goPath string) *Module {
+	return &Module{
+		GoPath:      goPath,
+		Imports:     orderedmap.NewOrderedMap[string, *Module](),
+		TypeImports: orderedmap.NewOrderedMap[string, *orderedset.OrderedSet[string]](),
+		Defs:        orderedmap.NewOrderedMap[string, string](),
+	}
 }
 
 func (m *Module) Render() []byte {
+	return m.RenderImports(ImportOptions{})
+}
+
+// RenderImports is like Render, but renders imports according to opts.
+func (m *Module) RenderImports(opts ImportOptions) []byte {
 	var b []byte
 	b = append(b, "/* "...)
 	b = append(b, m.GoPath...)
 	b = append(b, " */"...)
-	for moduleName := range m.Imports.Keys() {
+	for moduleName, imported := range m.Imports.AllFromFront() {
 		b = append(b, '\n')
-		b = append(b, "import * as "...)
+		if opts.TypeOnly {
+			b = append(b, "import type * as "...)
+		} else {
+			b = append(b, "import * as "...)
+		}
 		b = append(b, moduleName...)
-		b = append(b, ` from "./`...)
-		b = append(b, moduleName...)
-		b = append(b, `";`...)
+		b = append(b, " from "...)
+		b = strconv.AppendQuote(b, opts.specifier(m, imported))
+		b = append(b, ';')
+	}
+	b = appendTypeImports(b, m.TypeImports)
+	for _, r := range m.Reexports {
+		specifier := strconv.Quote(opts.specifier(m, r.Module))
+		if r.Namespace != "" {
+			b = append(b, "\nexport * as "...)
+			b = append(b, r.Namespace...)
+			b = append(b, " from "...)
+			b = append(b, specifier...)
+			b = append(b, ';')
+		}
+		if len(r.Types) > 0 {
+			b = append(b, "\nexport type { "...)
+			b = append(b, strings.Join(r.Types, ", ")...)
+			b = append(b, " } from "...)
+			b = append(b, specifier...)
+			b = append(b, ';')
+		}
+		if len(r.Values) > 0 {
+			b = append(b, "\nexport { "...)
+			b = append(b, strings.Join(r.Values, ", ")...)
+			b = append(b, " } from "...)
+			b = append(b, specifier...)
+			b = append(b, ';')
+		}
 	}
 	for def := range m.Defs.Values() {
 		b = append(b, "\n\n"...)
@@ -71,3 +245,20 @@ func (m *Module) Render() []byte {
 	}
 	return b
 }
+
+func appendTypeImports(b []byte, typeImports *orderedmap.OrderedMap[string, *orderedset.OrderedSet[string]]) []byte {
+	for from, names := range typeImports.AllFromFront() {
+		b = append(b, '\n')
+		b = append(b, "import type { "...)
+		for i, name := range names.All() {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = append(b, name...)
+		}
+		b = append(b, " } from "...)
+		b = strconv.AppendQuote(b, from)
+		b = append(b, ';')
+	}
+	return b
+}
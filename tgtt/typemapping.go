@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2025 Antoni Szymański
+// SPDX-License-Identifier: MPL-2.0
+
+package tgtt
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/antoniszymanski/collections-go/orderedset"
+)
+
+// TypeMapping replaces the transpiled form of a Go type with Type.
+// If From is set, Type is imported from the TypeScript module From
+// using `import type { Type } from "From"`, so it must be an identifier.
+type TypeMapping struct {
+	Type string `json:"type" jsonschema:"required,minLength=1"`
+	From string `json:"from,omitzero"`
+}
+
+func (m TypeMapping) MarshalJSON() ([]byte, error) {
+	if m.From == "" {
+		return json.Marshal(m.Type)
+	}
+	type RawTypeMapping TypeMapping
+	return json.Marshal(RawTypeMapping(m))
+}
+
+func (m *TypeMapping) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*m = TypeMapping{}
+		return json.Unmarshal(data, &m.Type)
+	}
+	type RawTypeMapping TypeMapping
+	return json.Unmarshal(data, (*RawTypeMapping)(m))
+}
+
+// mergeTypeMappings merges TranspileOptions.TypeMappings and
+// TranspileOptions.ImportedTypeMappings.
+func mergeTypeMappings(plain map[string]string, imported map[string]TypeMapping) (map[string]TypeMapping, error) {
+	mappings := make(map[string]TypeMapping, len(plain)+len(imported))
+	for key, typ := range plain {
+		mappings[key] = TypeMapping{Type: typ}
+	}
+	for key, m := range imported {
+		if _, ok := plain[key]; ok {
+			return nil, fmt.Errorf("type mapping %q is set both with and without an import", key)
+		}
+		if m.From != "" && tsIdent(m.Type) != m.Type {
+			return nil, fmt.Errorf("type mapping %q: type %q imported from %q is not an identifier", key, m.Type, m.From)
+		}
+		mappings[key] = m
+	}
+	return mappings, nil
+}
+
+func (t *transpiler) transpileTypeMapping(dst []byte, m TypeMapping, mod *Module) []byte {
+	if m.From != "" {
+		t.shared.mu.Lock()
+		names, ok := mod.TypeImports.Get(m.From)
+		if !ok {
+			names = orderedset.New[string](0)
+			mod.TypeImports.Set(m.From, names)
+		}
+		names.Insert(m.Type)
+		t.shared.mu.Unlock()
+		if t.rec != nil {
+			t.rec.TypeImports = append(t.rec.TypeImports, cachedTypeImport{From: m.From, Type: m.Type})
+		}
+	}
+	if m.Type == "any" || m.Type == "unknown" {
+		t.warn(mod, CodeLossyMapping, "type mapped to %s", m.Type)
+	}
+	return append(dst, m.Type...)
+}
+
+// typeMapper looks up type mappings by qualified name. Keys containing
+// '*' (any sequence of characters) or '?' (any single character) are
+// treated as patterns. An exact key takes precedence over patterns, and
+// among patterns the one with the most literal characters wins. If no
+// mapping matches, the lookup continues in next.
+type typeMapper struct {
+	exact    map[string]TypeMapping
+	patterns []typeMappingPattern
+	next     *typeMapper
+}
+
+type typeMappingPattern struct {
+	key     string
+	re      *regexp.Regexp
+	literal int // number of literal characters
+	mapping TypeMapping
+}
+
+// newTypeMapper creates a typeMapper from layers of mappings, where
+// earlier layers take precedence over later ones.
+func newTypeMapper(layers ...map[string]TypeMapping) typeMapper {
+	if len(layers) == 0 {
+		return typeMapper{}
+	}
+	mappings := layers[0]
+	m := typeMapper{exact: make(map[string]TypeMapping, len(mappings))}
+	if len(layers) > 1 {
+		next := newTypeMapper(layers[1:]...)
+		m.next = &next
+	}
+	for key, mapping := range mappings {
+		if !strings.ContainsAny(key, "*?") {
+			m.exact[key] = mapping
+			continue
+		}
+		re, literal := compileGlob(key)
+		m.patterns = append(m.patterns, typeMappingPattern{
+			key:     key,
+			re:      re,
+			literal: literal,
+			mapping: mapping,
+		})
+	}
+	slices.SortFunc(m.patterns, func(a, b typeMappingPattern) int {
+		if c := cmp.Compare(b.literal, a.literal); c != 0 {
+			return c
+		}
+		return strings.Compare(a.key, b.key)
+	})
+	return m
+}
+
+// compileGlob compiles a pattern in which '*' matches any sequence of
+// characters and '?' matches any single character. It also returns the
+// number of literal characters of the pattern.
+func compileGlob(pattern string) (re *regexp.Regexp, literal int) {
+	var expr strings.Builder
+	expr.WriteByte('^')
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteByte('.')
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			literal++
+		}
+	}
+	expr.WriteByte('$')
+	return regexp.MustCompile(expr.String()), literal
+}
+
+func (m *typeMapper) lookup(qualifiedName string) (TypeMapping, bool) {
+	if mapping, ok := m.exact[qualifiedName]; ok {
+		return mapping, true
+	}
+	for _, p := range m.patterns {
+		if p.re.MatchString(qualifiedName) {
+			return p.mapping, true
+		}
+	}
+	if m.next != nil {
+		return m.next.lookup(qualifiedName)
+	}
+	return TypeMapping{}, false
+}
@@ -3,57 +3,83 @@
 
 package tgtt
 
-import "go/types"
+import (
+	"go/types"
+
+	"github.com/antoniszymanski/collections-go/set"
+)
 
 func (t *transpiler) transpileTypeRef(dst []byte, tname *types.TypeName, mod *Module) []byte {
 	if tname.Pkg() == nil {
 		switch tname.Name() {
 		case "comparable":
+			t.shared.mu.Lock()
 			mod.Imports.Set("$builtin", t.modules.builtin())
+			t.recordImport("$builtin")
+			t.shared.mu.Unlock()
 			return append(dst, "$builtin.comparable"...)
 		case "error":
+			t.warn(mod, CodeErrorType, "error emitted as any")
 			return append(dst, "any /* error */"...)
+		case "any":
+			t.warnAny(mod)
+			return append(dst, "any"...)
 		default:
 			return append(dst, tname.Name()...)
 		}
 	}
 
 	pkg := t.packages[tname.Pkg().Path()]
-	typeMod := t.modules[pkg.Name]
-	if typeMod == nil {
-		typeMod = t.addModule(pkg)
+	t.shared.mu.Lock()
+	typeMod, added, err := t.moduleOf(pkg)
+	if err != nil {
+		if t.shared.err == nil {
+			t.shared.err = err
+		}
+		t.shared.mu.Unlock()
+		return append(dst, t.fallbackType...)
+	}
+	if typeMod != mod {
+		mod.Imports.Set(pkg.Name, typeMod)
+		t.recordImport(pkg.Name)
+	}
+	t.shared.mu.Unlock()
+	if added {
+		t.report(Event{Kind: EventModuleStarted, PkgPath: pkg.PkgPath, Module: pkg.Name})
 	}
 
-	for _, obj := range sortedDefs(pkg) {
-		isMatchingConstType := func() bool {
-			obj, ok := obj.(*types.Const)
-			if !ok {
-				return false
-			}
-			typ, ok := obj.Type().(*types.Named)
-			if !ok {
-				return false
-			}
-			return areObjectsEqual(tname, typ.Obj())
-		}()
-		if obj.Name() == tname.Name() {
-			t.transpileObject(obj, typeMod)
-		} else if isMatchingConstType && (obj.Exported() || t.includeUnexported) {
+	for _, obj := range t.indexOf(pkg).byName[tname.Name()] {
+		t.transpileObject(obj, typeMod)
+	}
+	for _, obj := range t.constsOf(tname) {
+		if t.isSelected(obj, set.Set[string]{}) {
 			t.transpileObject(obj, typeMod)
 		}
 	}
 
 	if typeMod == mod {
-		dst = append(dst, tname.Name()...)
+		dst = append(dst, t.tsName(tname)...)
 	} else {
-		mod.Imports.Set(pkg.Name, typeMod)
 		dst = append(dst, pkg.Name...)
 		dst = append(dst, '.')
-		dst = append(dst, tname.Name()...)
+		dst = append(dst, t.tsName(tname)...)
 	}
 	return dst
 }
 
+// recordImport records that the definition being transpiled imports the
+// module name.
+func (t *transpiler) recordImport(name string) {
+	if t.rec != nil {
+		t.rec.Imports = append(t.rec.Imports, name)
+	}
+}
+
+// constsOf returns the constants of the named type tname.
+func (t *transpiler) constsOf(tname *types.TypeName) []*types.Const {
+	return t.indexOf(t.packages[tname.Pkg().Path()]).consts[keyOf(tname)]
+}
+
 func (t *transpiler) transpileTypeArgs(dst []byte, targs *types.TypeList, mod *Module) []byte {
 	if targs.Len() == 0 {
 		return dst
@@ -77,9 +103,11 @@ func (t *transpiler) transpileTypeParams(dst []byte, tparams *types.TypeParamLis
 	dst = append(dst, '<')
 	for i := range tparams.Len() {
 		tparam := tparams.At(i)
-		dst = append(dst, tparam.Obj().Name()...)
+		dst = append(dst, tsIdent(tparam.Obj().Name())...)
 		dst = append(dst, " extends "...)
+		restore := t.setInConstraint(true)
 		dst = t.transpileType(dst, tparam.Constraint(), mod)
+		restore()
 		if i < tparams.Len()-1 {
 			dst = append(dst, ", "...)
 		}
@@ -98,17 +126,3 @@ func (t *transpiler) qualifiedName(obj types.Object) string {
 		return pkg.Path() + "." + obj.Name()
 	}
 }
-
-func areObjectsEqual(objA, objB types.Object) bool {
-	var pkgPathA, pkgPathB string
-	if pkg := objA.Pkg(); pkg != nil {
-		pkgPathA = pkg.Path()
-	}
-	if pkg := objB.Pkg(); pkg != nil {
-		pkgPathB = pkg.Path()
-	}
-	if pkgPathA != pkgPathB {
-		return false
-	}
-	return objA.Name() == objB.Name()
-}